@@ -0,0 +1,173 @@
+package taskgraph
+
+import (
+	"errors"
+	"fmt"
+
+	set "github.com/deckarep/golang-set/v2"
+)
+
+// ErrUndeclaredDependency is the error in the Absent binding returned to a task by its Binder when
+// the task reads a key which it has not declared as a dependency, if strict dependencies are
+// enabled (see WithStrictDependencies).
+var ErrUndeclaredDependency = errors.New("undeclared dependency")
+
+// ViolationKind represents the type of a DependencyViolation.
+type ViolationKind int
+
+const (
+	// UndeclaredDependency represents where a task has read a key (using Binder.Get or Binder.Has)
+	// which it has neither declared as a dependency nor as a key which it provides.
+	UndeclaredDependency ViolationKind = iota
+
+	// UnusedDependency represents where a task has completed successfully without reading a key which
+	// it has declared as a dependency. This is not necessarily a bug (e.g. a Conditional task which
+	// is not executed will not read the wrapped task's dependencies), but it causes the task to wait
+	// unnecessarily for the key to be bound.
+	UnusedDependency
+)
+
+func (vk ViolationKind) String() string {
+	return map[ViolationKind]string{
+		UndeclaredDependency: "UNDECLARED",
+		UnusedDependency:     "UNUSED",
+	}[vk]
+}
+
+// A DependencyViolation records a task accessing its Binder inconsistently with its declared
+// dependencies.
+type DependencyViolation struct {
+	// Kind of the violation.
+	Kind ViolationKind
+
+	// Task is the name of the task which caused the violation.
+	Task string
+
+	// Location is the location of the task which caused the violation.
+	Location string
+
+	// ID is the ID of the key which was read without being declared, or declared without being read.
+	ID ID
+
+	// RunID is the ID of the run in which the violation occurred (see RunIDFromContext).
+	RunID string
+}
+
+func (v DependencyViolation) String() string {
+	return fmt.Sprintf("%s(%s: task %s - %s)", v.Kind, v.ID, v.Task, v.Location)
+}
+
+// StrictDependencies configures the auditing of tasks' access to their Binders; see
+// WithStrictDependencies.
+type StrictDependencies struct {
+	// RecordOnly, if true, allows tasks to read keys which they have not declared as dependencies
+	// (such accesses are still reported as violations). By default, Get returns an Absent binding
+	// with ErrUndeclaredDependency for undeclared keys, and Has returns false.
+	RecordOnly bool
+
+	// Report is called with each violation found. It may be called concurrently from multiple tasks.
+	// If unset, violations are logged using the graph's Logger.
+	Report func(DependencyViolation)
+}
+
+// auditBinder implements Binder to provide a view of a graph's Binder for a single task, recording
+// the IDs which the task reads and reporting any which the task has not declared.
+type auditBinder struct {
+	Binder
+	task     Task
+	runID    string
+	strict   StrictDependencies
+	declared set.Set[ID]
+	allowed  set.Set[ID]
+	read     set.Set[ID]
+	reported set.Set[ID]
+}
+
+func newAuditBinder(b Binder, t Task, strict StrictDependencies, runID string) *auditBinder {
+	declared := set.NewSet[ID](t.Depends()...)
+	allowed := declared.Clone()
+	// Tasks may read the keys they provide; in particular the tasks within a graph run by AsTask()
+	// read exposed keys from the parent graph's Binder.
+	allowed.Append(t.Provides()...)
+	// Optional dependencies are read if bound, but are not reported if unused.
+	allowed.Append(optionalDependsOf(t)...)
+	return &auditBinder{
+		Binder:   b,
+		task:     t,
+		runID:    runID,
+		strict:   strict,
+		declared: declared,
+		allowed:  allowed,
+		read:     set.NewSet[ID](),
+		reported: set.NewSet[ID](),
+	}
+}
+
+// check records that id has been read, returning whether the task is permitted to read it.
+func (ab *auditBinder) check(id ID) bool {
+	if ab.allowed.Contains(id) {
+		ab.read.Add(id)
+		return true
+	}
+	if ab.reported.Add(id) {
+		ab.strict.Report(DependencyViolation{
+			Kind:     UndeclaredDependency,
+			Task:     ab.task.Name(),
+			Location: ab.task.Location(),
+			ID:       id,
+			RunID:    ab.runID,
+		})
+	}
+	return ab.strict.RecordOnly
+}
+
+func (ab *auditBinder) Has(ids ...ID) bool {
+	permitted := true
+	for _, id := range ids {
+		if !ab.check(id) {
+			permitted = false
+		}
+	}
+	return permitted && ab.Binder.Has(ids...)
+}
+
+func (ab *auditBinder) Get(id ID) Binding {
+	if !ab.check(id) {
+		return bindAbsentWithError(
+			id,
+			fmt.Errorf("task %s: %w: %s", ab.task.Name(), ErrUndeclaredDependency, id),
+		)
+	}
+	return ab.Binder.Get(id)
+}
+
+// reportUnused reports any declared dependencies which have not been read.
+func (ab *auditBinder) reportUnused() {
+	unused := ab.declared.Difference(ab.read).ToSlice()
+	sortIDs(unused)
+	for _, id := range unused {
+		ab.strict.Report(DependencyViolation{
+			Kind:     UnusedDependency,
+			Task:     ab.task.Name(),
+			Location: ab.task.Location(),
+			ID:       id,
+			RunID:    ab.runID,
+		})
+	}
+}
+
+// WithStrictDependencies enables auditing of the keys read by each task from its Binder, to detect
+// tasks which read keys they have not declared as dependencies (which may or may not have been
+// bound when the task is run, depending on the order in which other tasks complete), and tasks
+// which declare dependencies they never read.
+//
+// Each task is given its own view of the graph's Binder; see StrictDependencies for how violations
+// are handled. Declared dependencies which were not read are reported once the task has completed
+// successfully.
+func WithStrictDependencies(strict StrictDependencies) GraphOption {
+	return func(opts *graphOptions) error {
+		opts.strict = &strict
+
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package taskgraph_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestStrictDependencies(t *testing.T) {
+	key1 := tg.NewKey[string]("key1")
+	key2 := tg.NewKey[string]("key2")
+	key3 := tg.NewKey[string]("key3")
+
+	// Reads key2 without declaring it, and declares key3 without reading it.
+	undeclaredTask := tg.SimpleTask(
+		"task",
+		key1,
+		func(_ context.Context, b tg.Binder) (string, error) {
+			return key2.Get(b)
+		},
+		key3.ID(),
+	)
+
+	newGraph := func(strict tg.StrictDependencies) tg.Graph {
+		return tgt.Must[tg.Graph](t)(tg.New(
+			"test_graph",
+			tg.WithTasks(undeclaredTask),
+			tg.WithStrictDependencies(strict),
+		))
+	}
+
+	t.Run("undeclared dependency fails", func(t *testing.T) {
+		tgt.Test{
+			Graph: newGraph(tg.StrictDependencies{Report: func(tg.DependencyViolation) {}}),
+			Inputs: []tg.Binding{
+				key2.Bind("foo"),
+				key3.Bind("bar"),
+			},
+			WantError: tg.ErrUndeclaredDependency,
+		}.Run(t)
+	})
+
+	t.Run("record only", func(t *testing.T) {
+		var mu sync.Mutex
+		var got []tg.DependencyViolation
+		tgt.Test{
+			Graph: newGraph(tg.StrictDependencies{
+				RecordOnly: true,
+				Report: func(v tg.DependencyViolation) {
+					mu.Lock()
+					defer mu.Unlock()
+					got = append(got, v)
+				},
+			}),
+			Inputs: []tg.Binding{
+				key2.Bind("foo"),
+				key3.Bind("bar"),
+			},
+			WantBindings: []tgt.BindingMatcher{
+				tgt.Match(key1.Bind("foo")),
+			},
+		}.Run(t)
+
+		want := []tg.DependencyViolation{
+			{
+				Kind:     tg.UndeclaredDependency,
+				Task:     "task",
+				Location: undeclaredTask.Location(),
+				ID:       key2.ID(),
+			},
+			{
+				Kind:     tg.UnusedDependency,
+				Task:     "task",
+				Location: undeclaredTask.Location(),
+				ID:       key3.ID(),
+			},
+		}
+		// The run ID is generated for each run.
+		if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b tg.ID) bool {
+			return a == b
+		}), cmpopts.IgnoreFields(tg.DependencyViolation{}, "RunID")); diff != "" {
+			t.Errorf("Unexpected diff in violations (-want, +got):\n%s", diff)
+		}
+		for _, v := range got {
+			if v.RunID == "" || v.RunID != got[0].RunID {
+				t.Errorf("expected violations to have the same run ID; got %v", got)
+			}
+		}
+	})
+
+	t.Run("declared dependencies", func(t *testing.T) {
+		tgt.Test{
+			Task: tg.SimpleTask1[string, string](
+				"task",
+				key1,
+				func(_ context.Context, arg string) (string, error) {
+					return arg, nil
+				},
+				key2,
+			),
+			Inputs: []tg.Binding{
+				key2.Bind("foo"),
+			},
+			WantBindings: []tgt.BindingMatcher{
+				tgt.Match(key1.Bind("foo")),
+			},
+			StrictDependencies:      true,
+			CheckUnusedDependencies: true,
+		}.Run(t)
+	})
+}
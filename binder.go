@@ -62,6 +62,10 @@ type binding struct {
 	status BindStatus
 	value  any
 	err    error
+
+	// info describes the key which created the binding, if it was created with NewKey or
+	// NewNamespacedKey.
+	info *keyInfo
 }
 
 func (b *binding) ID() ID {
@@ -245,6 +249,10 @@ func NewOverlayBinder(base, overlay Binder) Binder {
 type graphTaskBinder struct {
 	internal, external Binder
 	exposeKeys         set.Set[ID]
+	// internalKeys are the keys provided by tasks in the graph which are not exposed, which are only
+	// read from the internal Binder (the parent graph's tasks must not be seen to read them while
+	// they are pending).
+	internalKeys set.Set[ID]
 }
 
 func (gtb *graphTaskBinder) Store(bindings ...Binding) error {
@@ -264,7 +272,10 @@ func (gtb *graphTaskBinder) Store(bindings ...Binding) error {
 
 func (gtb *graphTaskBinder) Has(ids ...ID) bool {
 	for _, id := range ids {
-		if !gtb.internal.Has(id) && !gtb.external.Has(id) {
+		if gtb.internal.Has(id) {
+			continue
+		}
+		if gtb.internalKeys.Contains(id) || !gtb.external.Has(id) {
 			return false
 		}
 	}
@@ -272,7 +283,7 @@ func (gtb *graphTaskBinder) Has(ids ...ID) bool {
 }
 
 func (gtb *graphTaskBinder) Get(id ID) Binding {
-	if ib := gtb.internal.Get(id); ib.Status() != Pending {
+	if ib := gtb.internal.Get(id); ib.Status() != Pending || gtb.internalKeys.Contains(id) {
 		return ib
 	}
 	return gtb.external.Get(id)
@@ -286,8 +297,9 @@ func (gtb *graphTaskBinder) GetAll() []Binding {
 // should not be called in production code.
 func TestOnlyNewGraphTaskBinder(internal, external Binder, exposeKeys set.Set[ID]) Binder {
 	return &graphTaskBinder{
-		internal:   internal,
-		external:   external,
-		exposeKeys: exposeKeys,
+		internal:     internal,
+		external:     external,
+		exposeKeys:   exposeKeys,
+		internalKeys: set.NewSet[ID](),
 	}
 }
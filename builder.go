@@ -15,6 +15,13 @@ type TaskBuilder[T any] struct {
 	defaultVal      T
 	defaultSet      bool
 	defaultBindings []Binding
+	cache           Cache
+	hasher          Hasher
+	sideEffect      bool
+	plan            PlanFunc
+	compensate      CompensateFunc
+	resources       map[string]int64
+	errors          []error
 }
 
 // NewTaskBuilder creates a new builder for a task that produces a result of type T.
@@ -68,8 +75,54 @@ func (b *TaskBuilder[T]) WithDefaultBindings(bindings ...Binding) *TaskBuilder[T
 	return b
 }
 
+// Cacheable marks the task as pure, such that its result is stored in the given cache and reused
+// when the task's dependencies are unchanged (see Cached). If hasher is nil, ReflectHasher is used.
+func (b *TaskBuilder[T]) Cacheable(cache Cache, hasher Hasher) *TaskBuilder[T] {
+	b.cache = cache
+	b.hasher = hasher
+	return b
+}
+
+// SideEffect marks the task as side-effecting (see SideEffect). When the graph is planned, plan is
+// called instead of the task's function; plan may be nil.
+func (b *TaskBuilder[T]) SideEffect(plan PlanFunc) *TaskBuilder[T] {
+	b.sideEffect = true
+	b.plan = plan
+	return b
+}
+
+// Compensate registers a compensating action for the task, which is run if the task completes
+// successfully but the run of the graph fails (see Compensated).
+func (b *TaskBuilder[T]) Compensate(fn CompensateFunc) *TaskBuilder[T] {
+	b.compensate = fn
+	return b
+}
+
+// Uses declares that the task consumes n tokens of the named resource while it runs (see
+// UsesResources). The tokens are not used while the task is skipped by its condition, or if its
+// result is cached. n must be positive.
+func (b *TaskBuilder[T]) Uses(name string, n int64) *TaskBuilder[T] {
+	if n <= 0 {
+		b.errors = append(b.errors, fmt.Errorf(
+			"resource %s: number of tokens must be positive; got %d",
+			name,
+			n,
+		))
+		return b
+	}
+	if b.resources == nil {
+		b.resources = map[string]int64{}
+	}
+	b.resources[name] += n
+	return b
+}
+
 // Build constructs and returns the Task.
 func (b *TaskBuilder[T]) Build() (TaskSet, error) {
+	if len(b.errors) > 0 {
+		return nil, errors.Join(b.errors...)
+	}
+
 	reflect := Reflect[T]{
 		Name:      b.name,
 		ResultKey: b.resultKey,
@@ -85,6 +138,44 @@ func (b *TaskBuilder[T]) Build() (TaskSet, error) {
 	}
 	var ts TaskSet = task
 
+	// Resources are used only while the task's function runs.
+	if len(b.resources) > 0 {
+		uses := UsesResources{
+			Wrapped:   ts,
+			Resources: b.resources,
+		}
+		uses.location = getLocation(2)
+		ts = uses
+	}
+
+	if b.cache != nil {
+		cached := Cached{
+			Wrapped: ts,
+			Cache:   b.cache,
+			Hasher:  b.hasher,
+		}
+		cached.location = getLocation(2)
+		ts = cached
+	}
+
+	if b.sideEffect {
+		sideEffect := SideEffect{
+			Wrapped: ts,
+			Plan:    b.plan,
+		}
+		sideEffect.location = getLocation(2)
+		ts = sideEffect
+	}
+
+	if b.compensate != nil {
+		compensated := Compensated{
+			Wrapped:    ts,
+			Compensate: b.compensate,
+		}
+		compensated.location = getLocation(2)
+		ts = compensated
+	}
+
 	if b.condition != nil {
 		conditional := Conditional{
 			Wrapped:   ts,
@@ -118,8 +209,15 @@ type MultiTaskBuilder struct {
 	depends         []any
 	fn              any
 	provides        []ID
+	provideDescs    []KeyDescriptor
 	condition       Condition
 	defaultBindings []Binding
+	cache           Cache
+	hasher          Hasher
+	sideEffect      bool
+	plan            PlanFunc
+	compensate      CompensateFunc
+	resources       map[string]int64
 	errors          []error
 }
 
@@ -150,6 +248,7 @@ func (b *MultiTaskBuilder) Provides(keys ...any) *MultiTaskBuilder {
 			continue
 		}
 		b.provides = append(b.provides, id)
+		b.provideDescs = append(b.provideDescs, describeKey(k)...)
 	}
 	return b
 }
@@ -185,6 +284,48 @@ func (b *MultiTaskBuilder) WithDefaultBindings(bindings ...Binding) *MultiTaskBu
 	return b
 }
 
+// Cacheable marks the task as pure, such that its bindings are stored in the given cache and reused
+// when the task's dependencies are unchanged (see Cached). If hasher is nil, ReflectHasher is used.
+func (b *MultiTaskBuilder) Cacheable(cache Cache, hasher Hasher) *MultiTaskBuilder {
+	b.cache = cache
+	b.hasher = hasher
+	return b
+}
+
+// SideEffect marks the task as side-effecting (see SideEffect). When the graph is planned, plan is
+// called instead of the task's function; plan may be nil.
+func (b *MultiTaskBuilder) SideEffect(plan PlanFunc) *MultiTaskBuilder {
+	b.sideEffect = true
+	b.plan = plan
+	return b
+}
+
+// Compensate registers a compensating action for the task, which is run if the task completes
+// successfully but the run of the graph fails (see Compensated).
+func (b *MultiTaskBuilder) Compensate(fn CompensateFunc) *MultiTaskBuilder {
+	b.compensate = fn
+	return b
+}
+
+// Uses declares that the task consumes n tokens of the named resource while it runs (see
+// UsesResources). The tokens are not used while the task is skipped by its condition, or if its
+// result is cached. n must be positive.
+func (b *MultiTaskBuilder) Uses(name string, n int64) *MultiTaskBuilder {
+	if n <= 0 {
+		b.errors = append(b.errors, fmt.Errorf(
+			"resource %s: number of tokens must be positive; got %d",
+			name,
+			n,
+		))
+		return b
+	}
+	if b.resources == nil {
+		b.resources = map[string]int64{}
+	}
+	b.resources[name] += n
+	return b
+}
+
 // Build constructs and returns the Task.
 func (b *MultiTaskBuilder) Build() (TaskSet, error) {
 	if len(b.errors) > 0 {
@@ -198,8 +339,47 @@ func (b *MultiTaskBuilder) Build() (TaskSet, error) {
 		Provides: b.provides,
 	}
 	reflect.location = getLocation(2)
+	reflect.provideDescs = b.provideDescs
 	var task TaskSet = reflect
 
+	// Resources are used only while the task's function runs.
+	if len(b.resources) > 0 {
+		uses := UsesResources{
+			Wrapped:   task,
+			Resources: b.resources,
+		}
+		uses.location = getLocation(2)
+		task = uses
+	}
+
+	if b.cache != nil {
+		cached := Cached{
+			Wrapped: task,
+			Cache:   b.cache,
+			Hasher:  b.hasher,
+		}
+		cached.location = getLocation(2)
+		task = cached
+	}
+
+	if b.sideEffect {
+		sideEffect := SideEffect{
+			Wrapped: task,
+			Plan:    b.plan,
+		}
+		sideEffect.location = getLocation(2)
+		task = sideEffect
+	}
+
+	if b.compensate != nil {
+		compensated := Compensated{
+			Wrapped:    task,
+			Compensate: b.compensate,
+		}
+		compensated.location = getLocation(2)
+		task = compensated
+	}
+
 	if b.condition != nil {
 		conditional := Conditional{
 			Wrapped:         task,
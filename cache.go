@@ -0,0 +1,271 @@
+package taskgraph
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Cache stores the bindings produced by pure tasks (see Cached), keyed on the name of the task
+// and a fingerprint of the bindings of its dependencies. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	// Get returns the bindings stored for the given key, and whether any were found.
+	Get(key string) ([]Binding, bool)
+
+	// Set stores bindings for the given key.
+	Set(key string, bindings []Binding)
+}
+
+// A Hasher produces a fingerprint of the given bindings, which are the bindings for a task's
+// dependencies sorted by ID. Bindings with equal values must produce equal fingerprints.
+type Hasher func(bindings []Binding) (string, error)
+
+// ReflectHasher is a Hasher which uses reflection to walk the values of the bindings, producing a
+// SHA-256 hash of their types and contents. Pointers are followed, map keys are sorted, and
+// unexported struct fields are included. It returns an error for values which cannot be hashed
+// meaningfully, such as functions and channels.
+func ReflectHasher(bindings []Binding) (string, error) {
+	h := sha256.New()
+	for _, b := range bindings {
+		fmt.Fprintf(h, "%s=%s(", b.ID(), b.Status())
+		switch b.Status() {
+		case Present:
+			if err := hashValue(h, reflect.ValueOf(b.Value()), 0); err != nil {
+				return "", wrapStackErrorf("cannot hash binding for %s: %w", b.ID(), err)
+			}
+		case Absent:
+			fmt.Fprintf(h, "%v", b.Error())
+		}
+		fmt.Fprint(h, ");")
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// maxHashDepth limits the recursion of hashValue, to protect against cyclic data structures.
+const maxHashDepth = 100
+
+func hashValue(w io.Writer, v reflect.Value, depth int) error {
+	if depth > maxHashDepth {
+		return fmt.Errorf("value nested more than %d levels deep", maxHashDepth)
+	}
+	if !v.IsValid() {
+		fmt.Fprint(w, "nil")
+		return nil
+	}
+	fmt.Fprintf(w, "%s:", v.Type())
+	switch v.Kind() {
+	case reflect.Bool:
+		fmt.Fprint(w, v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fmt.Fprint(w, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr:
+		fmt.Fprint(w, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		fmt.Fprint(w, v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		fmt.Fprint(w, v.Complex())
+	case reflect.String:
+		fmt.Fprintf(w, "%q", v.String())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			fmt.Fprint(w, "nil")
+			return nil
+		}
+		return hashValue(w, v.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			fmt.Fprint(w, "nil")
+			return nil
+		}
+		fmt.Fprintf(w, "%d[", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := hashValue(w, v.Index(i), depth+1); err != nil {
+				return err
+			}
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "]")
+	case reflect.Map:
+		if v.IsNil() {
+			fmt.Fprint(w, "nil")
+			return nil
+		}
+		// Hash each entry separately and sort the results, as map iteration order is random.
+		var entries []string
+		iter := v.MapRange()
+		for iter.Next() {
+			entry := sha256.New()
+			if err := hashValue(entry, iter.Key(), depth+1); err != nil {
+				return err
+			}
+			fmt.Fprint(entry, "=")
+			if err := hashValue(entry, iter.Value(), depth+1); err != nil {
+				return err
+			}
+			entries = append(entries, hex.EncodeToString(entry.Sum(nil)))
+		}
+		sort.Strings(entries)
+		fmt.Fprintf(w, "%d%v", len(entries), entries)
+	case reflect.Struct:
+		fmt.Fprint(w, "{")
+		for i := 0; i < v.NumField(); i++ {
+			fmt.Fprintf(w, "%s=", v.Type().Field(i).Name)
+			if err := hashValue(w, v.Field(i), depth+1); err != nil {
+				return err
+			}
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "}")
+	default:
+		return fmt.Errorf("cannot hash value of kind %s", v.Kind())
+	}
+	return nil
+}
+
+// Cached wraps pure tasks (i.e. tasks whose bindings depend only on the bindings of their
+// dependencies, and which have no side effects) such that their bindings are stored in a Cache.
+// When a wrapped task is run with dependencies which have the same fingerprint as a previous run,
+// the cached bindings are used without calling the task's Execute method.
+//
+// Errors returned by the wrapped tasks are not cached. Cache keys are formed from the task name and
+// the fingerprint, so a Cache should not be shared between graphs containing different tasks with
+// the same name.
+type Cached struct {
+	Wrapped TaskSet
+	Cache   Cache
+
+	// Hasher is used to fingerprint the bindings of each task's dependencies. If unset,
+	// ReflectHasher is used.
+	Hasher   Hasher
+	location string
+}
+
+// Locate annotates the Cached with its location in the source code, to make error messages easier
+// to understand. Calling it is recommended.
+func (c Cached) Locate() Cached {
+	c.location = getLocation(2)
+	return c
+}
+
+// Tasks satisfies TaskSet.Tasks.
+func (c Cached) Tasks() []Task {
+	hasher := c.Hasher
+	if hasher == nil {
+		hasher = ReflectHasher
+	}
+	var res []Task
+	for _, t := range c.Wrapped.Tasks() {
+		// t is captured by the fn closure below
+		t := t
+		ct := copyTask(t)
+		if c.location != "" {
+			ct.location = c.location
+		}
+		ct.fn = func(ctx context.Context, b Binder) ([]Binding, error) {
+			deps := append([]ID{}, t.Depends()...)
+			sortIDs(deps)
+			var bindings []Binding
+			for _, id := range deps {
+				bindings = append(bindings, b.Get(id))
+			}
+			fingerprint, err := hasher(bindings)
+			if err != nil {
+				return nil, wrapStackErrorf("fingerprinting dependencies: %w", err)
+			}
+			cacheKey := fmt.Sprintf("%s:%s", t.Name(), fingerprint)
+
+			if cached, ok := c.Cache.Get(cacheKey); ok {
+				cacheRequests.WithLabelValues(t.Name(), "hit").Inc()
+				return cached, nil
+			}
+			cacheRequests.WithLabelValues(t.Name(), "miss").Inc()
+
+			res, err := t.Execute(ctx, b)
+			if err != nil {
+				return nil, err
+			}
+			c.Cache.Set(cacheKey, res)
+			return res, nil
+		}
+		res = append(res, ct)
+	}
+	return res
+}
+
+type lruEntry struct {
+	key      string
+	bindings []Binding
+	expiry   time.Time
+}
+
+// lruCache is an in-memory Cache which evicts the least recently used entries.
+type lruCache struct {
+	sync.Mutex
+
+	size    int
+	ttl     time.Duration
+	entries *list.List
+	byKey   map[string]*list.Element
+	now     func() time.Time
+}
+
+func (c *lruCache) Get(key string) ([]Binding, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.byKey[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if c.ttl > 0 && c.now().After(entry.expiry) {
+		c.entries.Remove(el)
+		delete(c.byKey, key)
+		return nil, false
+	}
+	c.entries.MoveToFront(el)
+	return entry.bindings, true
+}
+
+func (c *lruCache) Set(key string, bindings []Binding) {
+	c.Lock()
+	defer c.Unlock()
+
+	entry := &lruEntry{
+		key:      key,
+		bindings: bindings,
+		expiry:   c.now().Add(c.ttl),
+	}
+	if el, ok := c.byKey[key]; ok {
+		el.Value = entry
+		c.entries.MoveToFront(el)
+		return
+	}
+	c.byKey[key] = c.entries.PushFront(entry)
+	for c.entries.Len() > c.size {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.byKey, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// NewLRUCache creates an in-memory Cache which holds at most size entries, evicting the least
+// recently used entry when full. If ttl is positive, entries expire once they are older than ttl.
+func NewLRUCache(size int, ttl time.Duration) Cache {
+	return &lruCache{
+		size:    size,
+		ttl:     ttl,
+		entries: list.New(),
+		byKey:   map[string]*list.Element{},
+		now:     time.Now,
+	}
+}
@@ -0,0 +1,110 @@
+package taskgraph
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCached(t *testing.T) {
+	in := NewKey[map[string]int]("in")
+	out := NewKey[int]("out")
+
+	calls := 0
+	task, err := NewTaskBuilder[int]("sum", out).
+		DependsOn(in).
+		Run(func(m map[string]int) int {
+			calls++
+			sum := 0
+			for _, v := range m {
+				sum += v
+			}
+			return sum
+		}).
+		Cacheable(NewLRUCache(10, 0), nil).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g, err := New("test_graph", WithTasks(task))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, tc := range []struct {
+		input     map[string]int
+		want      int
+		wantCalls int
+	}{
+		{map[string]int{"a": 1, "b": 2}, 3, 1},
+		{map[string]int{"b": 2, "a": 1}, 3, 1},
+		{map[string]int{"a": 1, "b": 3}, 4, 2},
+	} {
+		res, err := g.Run(context.Background(), in.Bind(tc.input))
+		if err != nil {
+			t.Fatalf("run %d: unexpected error: %v", i, err)
+		}
+		if got, err := out.Get(res); err != nil || got != tc.want {
+			t.Errorf("run %d: got (%v, %v); want %v", i, got, err, tc.want)
+		}
+		if calls != tc.wantCalls {
+			t.Errorf("run %d: got %d calls; want %d", i, calls, tc.wantCalls)
+		}
+	}
+}
+
+func TestReflectHasher(t *testing.T) {
+	key := NewKey[any]("key")
+	type nested struct {
+		name  string
+		items []*int
+	}
+	one, two := 1, 2
+
+	hash := func(val any) string {
+		h, err := ReflectHasher([]Binding{key.Bind(val)})
+		if err != nil {
+			t.Fatalf("unexpected error hashing %v: %v", val, err)
+		}
+		return h
+	}
+
+	if hash(nested{"a", []*int{&one}}) != hash(nested{"a", []*int{&one}}) {
+		t.Error("expected equal values to have equal hashes")
+	}
+	if hash(nested{"a", []*int{&one}}) == hash(nested{"a", []*int{&two}}) {
+		t.Error("expected values with different pointees to have different hashes")
+	}
+	if hash(int32(1)) == hash(int64(1)) {
+		t.Error("expected values of different types to have different hashes")
+	}
+	if _, err := ReflectHasher([]Binding{key.Bind(func() {})}); err == nil {
+		t.Error("expected error hashing a function")
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	key := NewKey[int]("key")
+	now := time.Now()
+	c := NewLRUCache(2, time.Minute).(*lruCache)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", []Binding{key.Bind(1)})
+	c.Set("b", []Binding{key.Bind(2)})
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected a to be cached")
+	}
+	// b is now the least recently used entry, so is evicted.
+	c.Set("c", []Binding{key.Bind(3)})
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Error("expected c to be cached")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a to have expired")
+	}
+}
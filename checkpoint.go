@@ -0,0 +1,140 @@
+package taskgraph
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Checkpoint records the progress of a graph run: the tasks which completed successfully, and the
+// bindings which they produced.
+type Checkpoint struct {
+	// Tasks contains the names of the completed tasks.
+	Tasks []string
+
+	// Bindings contains the bindings produced by the completed tasks.
+	Bindings []Binding
+}
+
+// A Checkpointer persists the progress of a graph run, so that it can be resumed with Graph.Resume
+// if it fails partway through.
+type Checkpointer interface {
+	// Save records that the named task has completed, producing the given bindings. It is called
+	// after the bindings have been stored in the graph's Binder, and may be called concurrently for
+	// different tasks.
+	Save(ctx context.Context, task string, bindings []Binding) error
+
+	// Load returns the progress previously recorded with Save.
+	Load(ctx context.Context) (Checkpoint, error)
+}
+
+type fileCheckpointer struct {
+	// Protects against concurrent writes creating the directory.
+	sync.Mutex
+
+	dir    string
+	codecs *CodecRegistry
+}
+
+type checkpointFile struct {
+	Task     string          `json:"task"`
+	Bindings json.RawMessage `json:"bindings"`
+}
+
+// filename returns a name for the task's checkpoint file which is safe to use on any filesystem.
+func (fc *fileCheckpointer) filename(task string) string {
+	hash := sha256.Sum256([]byte(task))
+	return filepath.Join(fc.dir, fmt.Sprintf("%s-%x.json", sanitizeTaskName(task), hash[:4]))
+}
+
+func (fc *fileCheckpointer) Save(_ context.Context, task string, bindings []Binding) error {
+	encoded, err := fc.codecs.MarshalBindings(bindings)
+	if err != nil {
+		return wrapStackErrorf("checkpointing task %s: %w", task, err)
+	}
+	data, err := json.Marshal(checkpointFile{Task: task, Bindings: encoded})
+	if err != nil {
+		return wrapStackErrorf("checkpointing task %s: %w", task, err)
+	}
+
+	fc.Lock()
+	err = os.MkdirAll(fc.dir, 0o755)
+	fc.Unlock()
+	if err != nil {
+		return wrapStackErrorf("checkpointing task %s: %w", task, err)
+	}
+
+	// Write to a temporary file and rename it, so that a partially written checkpoint is never read.
+	f, err := os.CreateTemp(fc.dir, ".tmp-*")
+	if err != nil {
+		return wrapStackErrorf("checkpointing task %s: %w", task, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return wrapStackErrorf("checkpointing task %s: %w", task, err)
+	}
+	if err := f.Close(); err != nil {
+		return wrapStackErrorf("checkpointing task %s: %w", task, err)
+	}
+	if err := os.Rename(f.Name(), fc.filename(task)); err != nil {
+		return wrapStackErrorf("checkpointing task %s: %w", task, err)
+	}
+	return nil
+}
+
+func (fc *fileCheckpointer) Load(_ context.Context) (Checkpoint, error) {
+	var cp Checkpoint
+	entries, err := os.ReadDir(fc.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	} else if err != nil {
+		return cp, wrapStackErrorf("loading checkpoint: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") ||
+			filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(fc.dir, entry.Name()))
+		if err != nil {
+			return cp, wrapStackErrorf("loading checkpoint: %w", err)
+		}
+		var cf checkpointFile
+		if err := json.Unmarshal(data, &cf); err != nil {
+			return cp, wrapStackErrorf("loading checkpoint %s: %w", entry.Name(), err)
+		}
+		bindings, err := fc.codecs.UnmarshalBindings(cf.Bindings)
+		if err != nil {
+			return cp, wrapStackErrorf("loading checkpoint for task %s: %w", cf.Task, err)
+		}
+		cp.Tasks = append(cp.Tasks, cf.Task)
+		cp.Bindings = append(cp.Bindings, bindings...)
+	}
+	sort.Strings(cp.Tasks)
+	return cp, nil
+}
+
+// NewFileCheckpointer creates a Checkpointer which stores the progress of a graph run as a JSON
+// file per completed task in the given directory (which is created if necessary). This is intended
+// for local use; each run which should be resumable needs its own directory.
+//
+// Bindings are marshalled using the given registry (or DefaultCodecs if nil); saving a checkpoint
+// fails if a task produces a present binding for a key with no codec.
+func NewFileCheckpointer(dir string, codecs *CodecRegistry) Checkpointer {
+	if codecs == nil {
+		codecs = DefaultCodecs
+	}
+	return &fileCheckpointer{
+		dir:    dir,
+		codecs: codecs,
+	}
+}
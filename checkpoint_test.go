@@ -0,0 +1,126 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestResume(t *testing.T) {
+	keyIn := tg.NewKey[int]("resume_in")
+	keyA := tg.NewKey[int]("resume_a", tg.WithCodec(tg.JSONCodec[int]{}))
+	keyB := tg.NewKey[string]("resume_b", tg.WithCodec(tg.JSONCodec[string]{}))
+	keyC := tg.NewKey[string]("resume_c", tg.WithCodec(tg.JSONCodec[string]{}))
+	sentinelError := errors.New("sentinel")
+
+	aCalls := 0
+	failB := true
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(
+		tg.SimpleTask1[int, int]("A", keyA, func(_ context.Context, in int) (int, error) {
+			aCalls++
+			return in * 2, nil
+		}, keyIn),
+		tg.NewTask("B", func(_ context.Context, b tg.Binder) ([]tg.Binding, error) {
+			if failB {
+				return nil, sentinelError
+			}
+			return []tg.Binding{keyB.BindError(sentinelError)}, nil
+		}, []tg.ID{keyA.ID()}, []tg.ID{keyB.ID()}),
+		tg.SimpleTask1[int, string]("C", keyC, func(_ context.Context, a int) (string, error) {
+			return "done", nil
+		}, keyA),
+	)))
+
+	checkpointer := tg.NewFileCheckpointer(t.TempDir(), nil)
+	if _, err := g.Resume(
+		context.Background(),
+		checkpointer,
+		keyIn.Bind(1),
+	); !errors.Is(err, sentinelError) {
+		t.Fatalf("expected error %v; got %v", sentinelError, err)
+	}
+
+	cp, err := checkpointer.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cp.Tasks) == 0 || cp.Tasks[0] != "A" {
+		t.Errorf("expected task A to have been checkpointed; got %v", cp.Tasks)
+	}
+
+	failB = false
+	res, err := g.Resume(context.Background(), checkpointer, keyIn.Bind(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aCalls != 1 {
+		t.Errorf("expected task A to be executed once; got %d", aCalls)
+	}
+	tgt.ExpectExactBindings(t, res, []tgt.BindingMatcher{
+		tgt.Match(keyA.Bind(2)),
+		tgt.Match(keyB.BindError(sentinelError)),
+		tgt.Match(keyC.Bind("done")),
+	})
+}
+
+func TestResumeAsTask(t *testing.T) {
+	keyIn := tg.NewKey[int]("resume_in")
+	keyInner := tg.NewKey[int]("resume_inner")
+	keyA := tg.NewKey[int]("resume_a", tg.WithCodec(tg.JSONCodec[int]{}))
+	keyB := tg.NewKey[int]("resume_b", tg.WithCodec(tg.JSONCodec[int]{}))
+	sentinelError := errors.New("sentinel")
+
+	inner := tgt.Must[tg.Graph](t)(tg.New("inner", tg.WithTasks(
+		tg.SimpleTask1[int, int]("inner", keyInner, func(_ context.Context, in int) (int, error) {
+			return in + 1, nil
+		}, keyIn),
+		tg.SimpleTask1[int, int]("A", keyA, func(_ context.Context, i int) (int, error) {
+			return i * 2, nil
+		}, keyInner),
+	)))
+	failB := true
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(
+		tgt.Must[tg.Task](t)(inner.AsTask(keyA.ID())),
+		tg.SimpleTask1[int, int]("B", keyB, func(_ context.Context, a int) (int, error) {
+			if failB {
+				return 0, sentinelError
+			}
+			return a + 1, nil
+		}, keyA),
+	)))
+
+	checkpointer := tg.NewFileCheckpointer(t.TempDir(), nil)
+	if _, err := g.Resume(
+		context.Background(),
+		checkpointer,
+		keyIn.Bind(1),
+	); !errors.Is(err, sentinelError) {
+		t.Fatalf("expected error %v; got %v", sentinelError, err)
+	}
+
+	// The exposed key is bound by the nested graph rather than returned by the task, but must still
+	// be checkpointed so that B does not wait for it forever.
+	failB = false
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := g.Resume(ctx, checkpointer, keyIn.Bind(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt.ExpectExactBindings(t, res, []tgt.BindingMatcher{
+		tgt.Match(keyA.Bind(4)),
+		tgt.Match(keyB.Bind(5)),
+	})
+}
+
+func TestFileCheckpointerMissingCodec(t *testing.T) {
+	key := tg.NewKey[int]("no_codec")
+	checkpointer := tg.NewFileCheckpointer(t.TempDir(), tg.NewCodecRegistry())
+	if err := checkpointer.Save(context.Background(), "task", []tg.Binding{key.Bind(1)}); err == nil {
+		t.Error("expected error saving binding with no codec")
+	}
+}
@@ -0,0 +1,272 @@
+package taskgraph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// A Codec converts values of type T to and from bytes, allowing bindings to be persisted (e.g. by a
+// Checkpointer).
+type Codec[T any] interface {
+	// Marshal encodes the value.
+	Marshal(val T) ([]byte, error)
+
+	// Unmarshal decodes a value previously encoded with Marshal.
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec is a Codec which encodes values using encoding/json.
+type JSONCodec[T any] struct{}
+
+// Marshal is Codec.Marshal.
+func (JSONCodec[T]) Marshal(val T) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+// Unmarshal is Codec.Unmarshal.
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var val T
+	err := json.Unmarshal(data, &val)
+	return val, err
+}
+
+// bindingCodec is a type-parameter-less wrapper around a Codec[T].
+type bindingCodec interface {
+	marshal(val any) ([]byte, error)
+	unmarshal(data []byte) (any, error)
+}
+
+type typedCodec[T any] struct {
+	codec Codec[T]
+}
+
+func (tc typedCodec[T]) marshal(val any) ([]byte, error) {
+	typed, ok := val.(T)
+	if !ok {
+		var want T
+		return nil, fmt.Errorf("%w (got %T, want %T)", ErrWrongType, val, want)
+	}
+	return tc.codec.Marshal(typed)
+}
+
+func (tc typedCodec[T]) unmarshal(data []byte) (any, error) {
+	return tc.codec.Unmarshal(data)
+}
+
+// A CodecRegistry maps key IDs to the Codecs used to persist their bindings, and names to the
+// sentinel errors which should be preserved when persisting Absent bindings.
+type CodecRegistry struct {
+	// Protects against concurrent access to the maps
+	sync.RWMutex
+
+	codecs map[ID]bindingCodec
+	errors map[string]error
+}
+
+// NewCodecRegistry returns a new CodecRegistry, containing no codecs, and the sentinel errors
+// defined by this package (e.g. ErrIsAbsent).
+func NewCodecRegistry() *CodecRegistry {
+	r := &CodecRegistry{
+		codecs: map[ID]bindingCodec{},
+		errors: map[string]error{},
+	}
+	r.RegisterError("taskgraph.ErrIsAbsent", ErrIsAbsent)
+	r.RegisterError("taskgraph.ErrIsPending", ErrIsPending)
+	r.RegisterError("taskgraph.ErrWrongType", ErrWrongType)
+	r.RegisterError("taskgraph.ErrUndeclaredDependency", ErrUndeclaredDependency)
+	return r
+}
+
+// DefaultCodecs is the CodecRegistry to which keys created with the WithCodec option are added, and
+// which is used by MarshalBindings and UnmarshalBindings.
+var DefaultCodecs = NewCodecRegistry()
+
+// RegisterError adds a sentinel error to the registry under the given name, which must be unique
+// and stable across program versions. When an Absent binding is marshalled, the names of all
+// registered errors which match its error (using errors.Is) are recorded, such that errors.Is
+// continues to match them once the binding is unmarshalled.
+func (r *CodecRegistry) RegisterError(name string, err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.errors[name] = err
+}
+
+// RegisterCodec adds the codec for the given key to the registry, replacing any codec previously
+// registered for the key's ID.
+func RegisterCodec[T any](r *CodecRegistry, key ReadOnlyKey[T], codec Codec[T]) {
+	r.register(key.ID(), typedCodec[T]{codec})
+}
+
+func (r *CodecRegistry) register(id ID, codec bindingCodec) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.codecs[id] = codec
+}
+
+func (r *CodecRegistry) lookup(id ID) (bindingCodec, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	codec, ok := r.codecs[id]
+	if !ok {
+		return nil, wrapStackErrorf("no codec registered for key %q", id)
+	}
+	return codec, nil
+}
+
+// WithCodec sets the Codec used to persist bindings for the key, and adds it to DefaultCodecs. The
+// codec must be a Codec[T] for a Key[T]; NewKey panics otherwise.
+func WithCodec(codec any) KeyOption {
+	return func(opts *keyOptions) {
+		opts.codec = codec
+	}
+}
+
+// encodedBinding is the serialised form of a Binding.
+type encodedBinding struct {
+	Namespace string   `json:"namespace,omitempty"`
+	ID        string   `json:"id"`
+	Status    string   `json:"status"`
+	Value     []byte   `json:"value,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Sentinels []string `json:"sentinels,omitempty"`
+}
+
+// decodedError is an error unmarshalled from an Absent binding, which wraps the sentinel errors
+// that the original error matched.
+type decodedError struct {
+	msg       string
+	sentinels []error
+}
+
+func (de *decodedError) Error() string {
+	return de.msg
+}
+
+func (de *decodedError) Unwrap() []error {
+	return de.sentinels
+}
+
+func (r *CodecRegistry) encode(b Binding) (encodedBinding, error) {
+	eb := encodedBinding{
+		Namespace: b.ID().namespace,
+		ID:        b.ID().id,
+		Status:    b.Status().String(),
+	}
+	switch b.Status() {
+	case Present:
+		codec, err := r.lookup(b.ID())
+		if err != nil {
+			return eb, err
+		}
+		if eb.Value, err = codec.marshal(b.Value()); err != nil {
+			return eb, wrapStackErrorf("cannot marshal binding for %s: %w", b.ID(), err)
+		}
+	case Absent:
+		if b.Error() == nil {
+			// Encoded without an error, and decoded as plain absent.
+			break
+		}
+		eb.Error = b.Error().Error()
+		r.RLock()
+		defer r.RUnlock()
+		for name, sentinel := range r.errors {
+			if errors.Is(b.Error(), sentinel) {
+				eb.Sentinels = append(eb.Sentinels, name)
+			}
+		}
+		sort.Strings(eb.Sentinels)
+	}
+	return eb, nil
+}
+
+func (r *CodecRegistry) decode(eb encodedBinding) (Binding, error) {
+	id := newID(eb.Namespace, eb.ID)
+	switch eb.Status {
+	case Pending.String():
+		return bindPending(id), nil
+	case Present.String():
+		codec, err := r.lookup(id)
+		if err != nil {
+			return nil, err
+		}
+		val, err := codec.unmarshal(eb.Value)
+		if err != nil {
+			return nil, wrapStackErrorf("cannot unmarshal binding for %s: %w", id, err)
+		}
+		return bind(id, val), nil
+	case Absent.String():
+		if eb.Error == "" && len(eb.Sentinels) == 0 {
+			return bindAbsentWithError(id, nil), nil
+		}
+		r.RLock()
+		defer r.RUnlock()
+		var sentinels []error
+		for _, name := range eb.Sentinels {
+			sentinel, ok := r.errors[name]
+			if !ok {
+				return nil, wrapStackErrorf(
+					"cannot unmarshal binding for %s: no error registered with name %q",
+					id,
+					name,
+				)
+			}
+			// Use the sentinel itself where it is the entire error, so that equality comparisons
+			// continue to work.
+			if len(eb.Sentinels) == 1 && sentinel.Error() == eb.Error {
+				return bindAbsentWithError(id, sentinel), nil
+			}
+			sentinels = append(sentinels, sentinel)
+		}
+		return bindAbsentWithError(id, &decodedError{msg: eb.Error, sentinels: sentinels}), nil
+	}
+	return nil, wrapStackErrorf("cannot unmarshal binding for %s with status %q", id, eb.Status)
+}
+
+// MarshalBindings serialises the bindings using the codecs in the registry. Pending and Absent
+// bindings are preserved; see RegisterError for how the errors of Absent bindings are preserved.
+func (r *CodecRegistry) MarshalBindings(bindings []Binding) ([]byte, error) {
+	ebs := []encodedBinding{}
+	for _, b := range bindings {
+		eb, err := r.encode(b)
+		if err != nil {
+			return nil, err
+		}
+		ebs = append(ebs, eb)
+	}
+	return json.Marshal(ebs)
+}
+
+// UnmarshalBindings deserialises bindings serialised by MarshalBindings, using the codecs in the
+// registry.
+func (r *CodecRegistry) UnmarshalBindings(data []byte) ([]Binding, error) {
+	var ebs []encodedBinding
+	if err := json.Unmarshal(data, &ebs); err != nil {
+		return nil, wrapStackErrorf("cannot unmarshal bindings: %w", err)
+	}
+	var bindings []Binding
+	for _, eb := range ebs {
+		b, err := r.decode(eb)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+// MarshalBindings serialises the bindings using DefaultCodecs; see CodecRegistry.MarshalBindings.
+func MarshalBindings(bindings []Binding) ([]byte, error) {
+	return DefaultCodecs.MarshalBindings(bindings)
+}
+
+// UnmarshalBindings deserialises bindings serialised by MarshalBindings using DefaultCodecs; see
+// CodecRegistry.UnmarshalBindings.
+func UnmarshalBindings(data []byte) ([]Binding, error) {
+	return DefaultCodecs.UnmarshalBindings(data)
+}
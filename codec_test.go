@@ -0,0 +1,82 @@
+package taskgraph
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalBindings(t *testing.T) {
+	registry := NewCodecRegistry()
+	sentinelError := errors.New("sentinel")
+	registry.RegisterError("test.sentinel", sentinelError)
+
+	keyPresent := NewNamespacedKey[[]string]("ns", "present")
+	keyAbsent := NewKey[int]("absent")
+	keySentinel := NewKey[int]("sentinel")
+	keyWrapped := NewKey[int]("wrapped")
+	keyPending := NewKey[int]("pending")
+	keyNilError := NewKey[int]("nil_error")
+	RegisterCodec(registry, keyPresent, JSONCodec[[]string]{})
+
+	data, err := registry.MarshalBindings([]Binding{
+		keyPresent.Bind([]string{"a", "b"}),
+		keyAbsent.BindAbsent(),
+		keySentinel.BindError(sentinelError),
+		keyWrapped.BindError(fmt.Errorf("wrapped: %w", sentinelError)),
+		bindPending(keyPending.ID()),
+		keyNilError.BindError(nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := registry.UnmarshalBindings(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 6 {
+		t.Fatalf("got %d bindings; want 6", len(got))
+	}
+
+	if got[0].ID() != keyPresent.ID() || got[0].Status() != Present ||
+		!reflect.DeepEqual(got[0].Value(), []string{"a", "b"}) {
+		t.Errorf("got %v; want %v", got[0], keyPresent.Bind([]string{"a", "b"}))
+	}
+	// Errors which are exactly a registered sentinel are restored as that sentinel.
+	if got[1].ID() != keyAbsent.ID() || got[1].Status() != Absent || got[1].Error() != ErrIsAbsent {
+		t.Errorf("got %v (%v); want %v", got[1], got[1].Error(), keyAbsent.BindAbsent())
+	}
+	if got[2].Status() != Absent || got[2].Error() != sentinelError {
+		t.Errorf("got %v (%v); want error %v", got[2], got[2].Error(), sentinelError)
+	}
+	// Other errors keep their message, and still match the sentinels they wrapped.
+	if got[3].Status() != Absent || !errors.Is(got[3].Error(), sentinelError) ||
+		got[3].Error().Error() != "wrapped: sentinel" {
+		t.Errorf("got %v (%v); want error wrapping %v", got[3], got[3].Error(), sentinelError)
+	}
+	if got[4].ID() != keyPending.ID() || got[4].Status() != Pending {
+		t.Errorf("got %v; want pending binding for %s", got[4], keyPending.ID())
+	}
+	if got[5].ID() != keyNilError.ID() || got[5].Status() != Absent || got[5].Error() != nil {
+		t.Errorf("got %v (%v); want %v", got[5], got[5].Error(), keyNilError.BindError(nil))
+	}
+}
+
+func TestMarshalBindingsErrors(t *testing.T) {
+	registry := NewCodecRegistry()
+	key := NewKey[int]("no_codec")
+	if _, err := registry.MarshalBindings([]Binding{key.Bind(1)}); err == nil {
+		t.Error("expected error marshalling binding with no codec")
+	}
+
+	sentinelError := errors.New("sentinel")
+	registry.RegisterError("test.sentinel", sentinelError)
+	data, err := registry.MarshalBindings([]Binding{key.BindError(sentinelError)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCodecRegistry().UnmarshalBindings(data); err == nil {
+		t.Error("expected error unmarshalling binding with unregistered error")
+	}
+}
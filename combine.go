@@ -0,0 +1,126 @@
+package taskgraph
+
+import (
+	"strings"
+)
+
+// combineNamespace is the namespace of the synthetic IDs of combined keys.
+const combineNamespace = "taskgraph.combine"
+
+// combinedKey is a virtual key whose value is derived from the values of several other keys.
+type combinedKey[Out any] struct {
+	keys     []any
+	get      func(b Binder) (Out, error)
+	location string
+}
+
+// ID returns a synthetic ID derived from the IDs of the underlying keys. It is never bound; tasks
+// and conditions using the key depend on the underlying keys instead.
+func (k *combinedKey[Out]) ID() ID {
+	var ids []string
+	for _, id := range k.DependencyIDs() {
+		ids = append(ids, id.String())
+	}
+	return newID(combineNamespace, strings.Join(ids, "+"))
+}
+
+func (k *combinedKey[Out]) Location() string {
+	return k.location
+}
+
+func (k *combinedKey[Out]) Get(b Binder) (Out, error) {
+	return k.get(b)
+}
+
+func (k *combinedKey[Out]) describe() []KeyDescriptor {
+	return describeVirtual(k.location, k.keys...)
+}
+
+func (k *combinedKey[Out]) DependencyIDs() []ID {
+	var ids []ID
+	for _, key := range k.keys {
+		ids = append(ids, keyDependencyIDs(key)...)
+	}
+	return ids
+}
+
+// Combine2 returns a ReadOnlyKey which applies the given function to the values of two keys when
+// Get() is called, returning the first error from the underlying keys. Tasks and conditions using
+// the returned key depend on both of the underlying keys.
+func Combine2[A1, A2, Out any](
+	key1 ReadOnlyKey[A1],
+	key2 ReadOnlyKey[A2],
+	fn func(A1, A2) Out,
+) ReadOnlyKey[Out] {
+	return &combinedKey[Out]{
+		keys: []any{key1, key2},
+		get: func(b Binder) (Out, error) {
+			var empty Out
+			v1, err := key1.Get(b)
+			if err != nil {
+				return empty, err
+			}
+			v2, err := key2.Get(b)
+			if err != nil {
+				return empty, err
+			}
+			return fn(v1, v2), nil
+		},
+		location: getLocation(2),
+	}
+}
+
+// Combine3 returns a ReadOnlyKey which applies the given function to the values of three keys when
+// Get() is called; see Combine2.
+func Combine3[A1, A2, A3, Out any](
+	key1 ReadOnlyKey[A1],
+	key2 ReadOnlyKey[A2],
+	key3 ReadOnlyKey[A3],
+	fn func(A1, A2, A3) Out,
+) ReadOnlyKey[Out] {
+	return &combinedKey[Out]{
+		keys: []any{key1, key2, key3},
+		get: func(b Binder) (Out, error) {
+			var empty Out
+			v1, err := key1.Get(b)
+			if err != nil {
+				return empty, err
+			}
+			v2, err := key2.Get(b)
+			if err != nil {
+				return empty, err
+			}
+			v3, err := key3.Get(b)
+			if err != nil {
+				return empty, err
+			}
+			return fn(v1, v2, v3), nil
+		},
+		location: getLocation(2),
+	}
+}
+
+// CombineN returns a ReadOnlyKey which applies the given function to the values of any number of
+// keys of the same type (in the order of the keys) when Get() is called; see Combine2.
+func CombineN[In, Out any](fn func([]In) Out, keys ...ReadOnlyKey[In]) ReadOnlyKey[Out] {
+	anyKeys := make([]any, len(keys))
+	for i, k := range keys {
+		anyKeys[i] = k
+	}
+	return &combinedKey[Out]{
+		keys: anyKeys,
+		get: func(b Binder) (Out, error) {
+			vals := make([]In, len(keys))
+			for i, k := range keys {
+				v, err := k.Get(b)
+				if err != nil {
+					var empty Out
+					return empty, err
+				}
+				vals[i] = v
+			}
+			return fn(vals), nil
+		},
+		location: getLocation(2),
+	}
+}
@@ -0,0 +1,141 @@
+package taskgraph_test
+
+import (
+	"context"
+	"testing"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestCombine(t *testing.T) {
+	keyA := tg.NewKey[int]("a")
+	keyB := tg.NewKey[string]("b")
+	keyC := tg.NewKey[bool]("c")
+	result := tg.NewKey[string]("result")
+	sum := tg.NewKey[int]("sum")
+	present := tg.NewKey[bool]("present")
+
+	combined := tg.Combine3(keyA, keyB, keyC, func(a int, b string, c bool) string {
+		if c {
+			return b
+		}
+		return ""
+	})
+
+	tgt.Suite{
+		Tests: []tgt.Test{
+			{
+				Description: "Reflect",
+				Task: tg.Reflect[string]{
+					Name:      "task",
+					ResultKey: result,
+					Fn: func(s string) string {
+						return s + s
+					},
+					Depends: []any{combined},
+				}.Locate(),
+				Inputs: []tg.Binding{keyA.Bind(1), keyB.Bind("x"), keyC.Bind(true)},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(result.Bind("xx")),
+				},
+				CheckExcessBindings:     true,
+				StrictDependencies:      true,
+				CheckUnusedDependencies: true,
+			},
+			{
+				Description: "Conditional",
+				Task: tg.Conditional{
+					Wrapped: tg.SimpleTask[string](
+						"task",
+						result,
+						func(_ context.Context, _ tg.Binder) (string, error) { return "ran", nil },
+					),
+					Condition: tg.ConditionAnd{
+						tg.Combine2(keyA, keyC, func(a int, c bool) bool { return a > 0 && c }),
+					},
+				}.Locate(),
+				Inputs: []tg.Binding{keyA.Bind(1), keyC.Bind(false)},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(result.BindAbsent()),
+				},
+				StrictDependencies:      true,
+				CheckUnusedDependencies: true,
+			},
+			{
+				Description: "CombineN",
+				Task: tg.SimpleTask1[int, int](
+					"task",
+					sum,
+					func(_ context.Context, s int) (int, error) { return s, nil },
+					tg.CombineN(func(vals []int) int {
+						total := 0
+						for _, v := range vals {
+							total += v
+						}
+						return total
+					}, keyA, tg.Mapped(keyB, func(s string) int { return len(s) })),
+				),
+				Inputs: []tg.Binding{keyA.Bind(1), keyB.Bind("abc")},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(sum.Bind(4)),
+				},
+				StrictDependencies:      true,
+				CheckUnusedDependencies: true,
+			},
+			{
+				Description: "Presence",
+				Task: tg.SimpleTask1[bool, bool](
+					"task",
+					present,
+					func(_ context.Context, p bool) (bool, error) { return p, nil },
+					tg.Presence(combined),
+				),
+				Inputs: []tg.Binding{keyA.Bind(1), keyB.Bind("x"), keyC.Bind(true)},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(present.Bind(true)),
+				},
+			},
+			{
+				Description: "Presence with absent key",
+				Task: tg.SimpleTask1[bool, bool](
+					"task",
+					present,
+					func(_ context.Context, p bool) (bool, error) { return p, nil },
+					tg.Presence(combined),
+				),
+				Inputs: []tg.Binding{keyA.Bind(1), keyB.BindAbsent(), keyC.Bind(true)},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(present.Bind(false)),
+				},
+			},
+		},
+	}.Run(t)
+}
+
+func TestCombineGraphWiring(t *testing.T) {
+	in := tg.NewKey[int]("in")
+	keyA := tg.NewKey[int]("a")
+	keyB := tg.NewKey[int]("b")
+	result := tg.NewKey[int]("result")
+	double := func(_ context.Context, i int) (int, error) { return i * 2, nil }
+
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(
+		tg.SimpleTask1[int, int]("a", keyA, double, in),
+		tg.SimpleTask1[int, int]("b", keyB, double, keyA),
+		tg.SimpleTask1[int, int](
+			"result",
+			result,
+			func(_ context.Context, i int) (int, error) { return i, nil },
+			tg.Combine2(keyA, keyB, func(a, b int) int { return a + b }),
+		),
+	)))
+	if err := g.Check(); err == nil {
+		t.Error("expected error checking graph without inputs")
+	}
+	res, err := g.Run(context.Background(), in.Bind(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt.ExpectPresent(t, res, result, 6)
+}
@@ -0,0 +1,186 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrCompensationFailed is returned (along with the error which caused the run to fail) if any
+// compensating action returns an error when a run fails.
+var ErrCompensationFailed = errors.New("compensation failed")
+
+// A CompensateFunc undoes the side effects of a task which completed successfully, when a later
+// task causes the run to fail. The Binder contains the bindings for the task's dependencies, and
+// those returned by the task.
+type CompensateFunc func(ctx context.Context, b Binder) error
+
+// Compensated is a TaskSet which registers a compensating action for each of the tasks in Wrapped,
+// to implement the saga pattern. If a run of the graph fails, the compensating actions of the tasks
+// which completed successfully are run, one at a time, in the reverse of the order in which the
+// tasks completed (so a task is compensated before any of the tasks it depends on). Compensating
+// actions are run with a context which is not cancelled when the run's context is cancelled, once
+// all of the tasks have finished (so tasks which complete after the run is cancelled are compensated
+// too).
+//
+// Compensating actions are run for tasks in nested graphs as part of the run of the outermost
+// graph, whether they are run as tasks created with Graph.AsTask, or with Graph.Run from within a
+// task (e.g. by a Map using ItemGraph, or by a Loop): if a nested run succeeds, the compensating
+// actions of its tasks are run if the outer run fails. Runs started with Graph.RunShared are not
+// compensated by the runs which call them.
+//
+// Compensating actions are not run by Graph.Plan, or for graphs created with WithoutCompensation
+// (including when those graphs are nested in graphs which do use compensation).
+type Compensated struct {
+	Wrapped    TaskSet
+	Compensate CompensateFunc
+	location   string
+}
+
+// Locate annotates the Compensated with its location in the source code, to make error messages
+// easier to understand. Calling it is recommended.
+func (c Compensated) Locate() Compensated {
+	c.location = getLocation(2)
+	return c
+}
+
+// Tasks satisfies TaskSet.Tasks.
+func (c Compensated) Tasks() []Task {
+	var res []Task
+	for _, t := range c.Wrapped.Tasks() {
+		// t is captured by the fn closure below
+		t := t
+		ct := copyTask(t)
+		if c.location != "" {
+			ct.location = c.location
+		}
+		ct.fn = func(ctx context.Context, b Binder) ([]Binding, error) {
+			res, err := t.Execute(ctx, b)
+			if err != nil {
+				return nil, err
+			}
+			if cl := compensationLogFromContext(ctx); cl != nil {
+				cb := NewBinder()
+				for _, id := range t.Depends() {
+					if err := cb.Store(b.Get(id)); err != nil {
+						return nil, err
+					}
+				}
+				if err := cb.Store(res...); err != nil {
+					return nil, err
+				}
+				cl.record(ct, c.Compensate, cb)
+			}
+			return res, nil
+		}
+		res = append(res, ct)
+	}
+	return res
+}
+
+// WithoutCompensation disables running compensating actions (see Compensated) when a run of the
+// graph fails.
+func WithoutCompensation() GraphOption {
+	return func(opts *graphOptions) error {
+		opts.noCompensation = true
+
+		return nil
+	}
+}
+
+type compensation struct {
+	task Task
+	fn   CompensateFunc
+	b    Binder
+}
+
+// compensationLog records the compensating actions of the tasks which have completed in a run.
+type compensationLog struct {
+	// Protects against concurrent access to compensations
+	sync.Mutex
+
+	compensations []compensation
+}
+
+func (cl *compensationLog) record(t Task, fn CompensateFunc, b Binder) {
+	cl.Lock()
+	defer cl.Unlock()
+
+	cl.compensations = append(cl.compensations, compensation{task: t, fn: fn, b: b})
+}
+
+// chain records the compensating actions of a nested run which succeeded in cl, so that they are
+// run if the run which cl belongs to fails.
+func (cl *compensationLog) chain(nested *compensationLog) {
+	nested.Lock()
+	compensations := append([]compensation{}, nested.compensations...)
+	nested.Unlock()
+
+	cl.Lock()
+	defer cl.Unlock()
+
+	cl.compensations = append(cl.compensations, compensations...)
+}
+
+type compensationLogContextKey struct{}
+
+// withCompensationLog returns a context in which compensating actions are recorded in cl, or are
+// not recorded if cl is nil.
+func withCompensationLog(ctx context.Context, cl *compensationLog) context.Context {
+	return context.WithValue(ctx, compensationLogContextKey{}, cl)
+}
+
+// compensationLogFromContext returns the compensationLog of the run in which the context was
+// created, or nil if compensating actions should not be recorded.
+func compensationLogFromContext(ctx context.Context) *compensationLog {
+	cl, _ := ctx.Value(compensationLogContextKey{}).(*compensationLog)
+	return cl
+}
+
+const (
+	traceTaskgraphCompensatePrefix = "taskgraph.compensate."
+)
+
+// compensate runs the recorded compensating actions in reverse order, returning an error wrapping
+// ErrCompensationFailed and the errors returned by any of them.
+func (g *graph) compensate(ctx context.Context, cl *compensationLog) error {
+	cl.Lock()
+	compensations := append([]compensation{}, cl.compensations...)
+	cl.Unlock()
+	if len(compensations) == 0 {
+		return nil
+	}
+
+	ctx, span := g.tracer.Start(context.WithoutCancel(ctx), g.name+" compensation")
+	defer span.End()
+	span.SetAttributes(attribute.Int(traceTaskgraphCompensatePrefix+"count", len(compensations)))
+
+	var errs error
+	for i := len(compensations) - 1; i >= 0; i-- {
+		c := compensations[i]
+		if err := g.compensateTask(ctx, c); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	if errs != nil {
+		span.RecordError(errs)
+		return wrapStackErrorf("%w: %w", ErrCompensationFailed, errs)
+	}
+	return nil
+}
+
+func (g *graph) compensateTask(ctx context.Context, c compensation) error {
+	ctx, span := g.tracer.Start(ctx, fmt.Sprintf("compensate %s", c.task.Name()))
+	defer span.End()
+	span.SetAttributes(attribute.String(traceTaskgraphCompensatePrefix+"task", c.task.Name()))
+
+	g.logger.Debugf("Compensating task %s", c.task.Name())
+	if err := c.fn(ctx, c.b); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("task %s (%s): %w", c.task.Name(), c.task.Location(), err)
+	}
+	return nil
+}
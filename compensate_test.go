@@ -0,0 +1,265 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+// compensationRecorder records the values of the keys of compensated tasks.
+type compensationRecorder struct {
+	// Protects against concurrent access to calls
+	sync.Mutex
+
+	calls []string
+}
+
+// compensate returns a compensating action which records the value bound to id.
+func (r *compensationRecorder) compensate(id tg.ID) tg.CompensateFunc {
+	return func(_ context.Context, b tg.Binder) error {
+		r.Lock()
+		defer r.Unlock()
+		r.calls = append(r.calls, fmt.Sprintf("undo %v", b.Get(id).Value()))
+		return nil
+	}
+}
+
+func TestCompensate(t *testing.T) {
+	keyA := tg.NewKey[string]("a")
+	keyB := tg.NewKey[string]("b")
+	keyC := tg.NewKey[string]("c")
+	errFail := errors.New("failed")
+
+	for _, test := range []struct {
+		description string
+		failErr     error
+		opts        []tg.GraphOption
+		want        []string
+	}{
+		{
+			description: "failed run",
+			failErr:     errFail,
+			// Tasks are compensated before the tasks they depend on.
+			want: []string{"undo ab", "undo a"},
+		},
+		{
+			description: "without compensation",
+			failErr:     errFail,
+			opts:        []tg.GraphOption{tg.WithoutCompensation()},
+		},
+		{
+			description: "successful run",
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			r := &compensationRecorder{}
+			g := tgt.Must[tg.Graph](t)(tg.New("test_graph", append([]tg.GraphOption{tg.WithTasks(
+				tg.Compensated{
+					Wrapped: tg.SimpleTask[string]("a", keyA, func(context.Context, tg.Binder) (string, error) {
+						return "a", nil
+					}),
+					Compensate: r.compensate(keyA.ID()),
+				}.Locate(),
+				tg.NewTaskBuilder[string]("b", keyB).
+					DependsOn(keyA).
+					Run(func(a string) string { return a + "b" }).
+					Compensate(r.compensate(keyB.ID())),
+				tg.SimpleTask1[string, string]("c", keyC, func(context.Context, string) (string, error) {
+					return "", test.failErr
+				}, keyB),
+			)}, test.opts...)...))
+
+			if _, err := g.Run(context.Background()); !errors.Is(err, test.failErr) {
+				t.Fatalf("got error %v; want %v", err, test.failErr)
+			}
+			if diff := cmp.Diff(test.want, r.calls); diff != "" {
+				t.Errorf("unexpected compensations (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompensateNested(t *testing.T) {
+	keyItems := tg.NewKey[[]int]("items")
+	keyItem := tg.NewKey[int]("item")
+	keyResult := tg.NewKey[int]("result")
+	keyResults := tg.NewKey[[]int]("results")
+	keyNext := tg.NewKey[int]("next")
+	errFail := errors.New("failed")
+
+	result := func(r *compensationRecorder) tg.TaskSet {
+		return tg.Compensated{
+			Wrapped: tg.SimpleTask[int]("result", keyResult, func(context.Context, tg.Binder) (int, error) {
+				return 1, nil
+			}),
+			Compensate: r.compensate(keyResult.ID()),
+		}.Locate()
+	}
+	fail := func(depends ...tg.ID) tg.Task {
+		return tg.NoOutputTask("fail", func(context.Context, tg.Binder) error {
+			return errFail
+		}, depends...)
+	}
+
+	for _, test := range []struct {
+		description string
+		tasks       func(r *compensationRecorder) tg.TaskSet
+		want        []string
+		// unordered is set if the compensated tasks run concurrently.
+		unordered bool
+	}{
+		{
+			description: "AsTask",
+			tasks: func(r *compensationRecorder) tg.TaskSet {
+				inner := tgt.Must[tg.Graph](t)(tg.New("inner", tg.WithTasks(result(r))))
+				return tg.NewTaskSet(
+					tgt.Must[tg.Task](t)(inner.AsTask(keyResult.ID())),
+					fail(keyResult.ID()),
+				)
+			},
+			want: []string{"undo 1"},
+		},
+		{
+			description: "AsTask without compensation",
+			tasks: func(r *compensationRecorder) tg.TaskSet {
+				inner := tgt.Must[tg.Graph](t)(tg.New(
+					"inner",
+					tg.WithTasks(result(r)),
+					tg.WithoutCompensation(),
+				))
+				return tg.NewTaskSet(
+					tgt.Must[tg.Task](t)(inner.AsTask(keyResult.ID())),
+					fail(keyResult.ID()),
+				)
+			},
+		},
+		{
+			description: "ItemGraph",
+			tasks: func(r *compensationRecorder) tg.TaskSet {
+				itemGraph := tgt.Must[tg.Graph](t)(tg.New("item_graph", tg.WithTasks(tg.Compensated{
+					Wrapped: tg.SimpleTask1[int, int](
+						"double",
+						keyResult,
+						func(_ context.Context, i int) (int, error) { return 2 * i, nil },
+						keyItem,
+					),
+					Compensate: r.compensate(keyResult.ID()),
+				}.Locate())))
+				return tg.NewTaskSet(
+					tg.Map[int, int]{
+						Name:   "map",
+						Input:  keyItems,
+						Fn:     tg.ItemGraph(itemGraph, keyItem, keyResult),
+						Output: keyResults,
+					}.Locate(),
+					fail(keyResults.ID()),
+				)
+			},
+			want:      []string{"undo 2", "undo 4"},
+			unordered: true,
+		},
+		{
+			description: "Loop",
+			tasks: func(r *compensationRecorder) tg.TaskSet {
+				return tg.NewTaskSet(
+					tg.Loop{
+						Name: "loop",
+						Body: tg.Compensated{
+							Wrapped: tg.SimpleTask1[int, int](
+								"next",
+								keyNext,
+								func(_ context.Context, i int) (int, error) { return i + 1, nil },
+								keyItem,
+							),
+							Compensate: r.compensate(keyNext.ID()),
+						}.Locate(),
+						Feedback:      map[tg.ID]tg.ID{keyNext.ID(): keyItem.ID()},
+						Until:         tg.Mapped(keyNext, func(i int) bool { return i >= 2 }),
+						Exposes:       []tg.ID{keyNext.ID()},
+						MaxIterations: 10,
+						Interval:      time.Millisecond,
+					}.Locate(),
+					fail(keyNext.ID()),
+				)
+			},
+			// Later iterations are compensated first.
+			want: []string{"undo 2", "undo 1"},
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			r := &compensationRecorder{}
+			g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(test.tasks(r))))
+
+			_, err := g.Run(context.Background(), keyItems.Bind([]int{1, 2}), keyItem.Bind(0))
+			if !errors.Is(err, errFail) {
+				t.Fatalf("got error %v; want %v", err, errFail)
+			}
+			var opts []cmp.Option
+			if test.unordered {
+				opts = append(opts, cmpopts.SortSlices(func(a, b string) bool { return a < b }))
+			}
+			if diff := cmp.Diff(test.want, r.calls, opts...); diff != "" {
+				t.Errorf("unexpected compensations (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompensateCancelled(t *testing.T) {
+	keyA := tg.NewKey[string]("a")
+
+	r := &compensationRecorder{}
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(tg.Compensated{
+		// Completes after the run has been cancelled.
+		Wrapped: tg.SimpleTask[string]("a", keyA, func(context.Context, tg.Binder) (string, error) {
+			time.Sleep(50 * time.Millisecond)
+			return "a", nil
+		}),
+		Compensate: r.compensate(keyA.ID()),
+	}.Locate())))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v; want %v", err, context.DeadlineExceeded)
+	}
+	r.Lock()
+	defer r.Unlock()
+	if diff := cmp.Diff([]string{"undo a"}, r.calls); diff != "" {
+		t.Errorf("unexpected compensations (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompensateFailure(t *testing.T) {
+	keyA := tg.NewKey[string]("a")
+	errFail := errors.New("failed")
+	errCompensate := errors.New("cannot undo")
+
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(
+		tg.Compensated{
+			Wrapped: tg.SimpleTask[string]("a", keyA, func(context.Context, tg.Binder) (string, error) {
+				return "a", nil
+			}),
+			Compensate: func(context.Context, tg.Binder) error { return errCompensate },
+		}.Locate(),
+		tg.NoOutputTask("fail", func(context.Context, tg.Binder) error {
+			return errFail
+		}, keyA.ID()),
+	)))
+
+	_, err := g.Run(context.Background())
+	for _, want := range []error{errFail, tg.ErrCompensationFailed, errCompensate} {
+		if !errors.Is(err, want) {
+			t.Errorf("expected error to wrap %v; got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// conditionTracer is implemented by conditions which can explain how they were evaluated.
+type conditionTracer interface {
+	// evaluateTrace is like Evaluate, but also returns a human-readable trace of the evaluation.
+	evaluateTrace(ctx context.Context, b Binder) (bool, string, error)
+}
+
+// evaluateCondition evaluates the condition, returning a human-readable trace of the evaluation.
+func evaluateCondition(ctx context.Context, c Condition, b Binder) (bool, string, error) {
+	if ct, ok := c.(conditionTracer); ok {
+		return ct.evaluateTrace(ctx, b)
+	}
+	v, err := c.Evaluate(ctx, b)
+	return v, traceResult(fmt.Sprintf("%T", c), v, err), err
+}
+
+// traceResult formats the result of evaluating a condition for a trace.
+func traceResult(expr string, v bool, err error) string {
+	if err != nil {
+		return fmt.Sprintf("%s=error(%v)", expr, err)
+	}
+	return fmt.Sprintf("%s=%t", expr, v)
+}
+
+// describeCondition returns descriptors for the keys read by the condition.
+func describeCondition(c Condition) []KeyDescriptor {
+	if kd, ok := c.(keyDescriber); ok {
+		return kd.describe()
+	}
+	return describeKeys(c.Keys()...)
+}
+
+// conditionList is the implementation of And and Or.
+type conditionList struct {
+	conds []Condition
+	// all is true for And, and false for Or.
+	all bool
+}
+
+// And returns a Condition which evaluates to true if and only if all of the given conditions
+// evaluate to true. Conditions are evaluated in order, stopping at the first which evaluates to
+// false. To use a boolean key as a condition, wrap it in a ConditionAnd.
+func And(conds ...Condition) Condition {
+	return &conditionList{conds: conds, all: true}
+}
+
+// Or returns a Condition which evaluates to true if any of the given conditions evaluate to true.
+// Conditions are evaluated in order, stopping at the first which evaluates to true.
+func Or(conds ...Condition) Condition {
+	return &conditionList{conds: conds, all: false}
+}
+
+// Evaluate is Condition.Evaluate.
+func (cl *conditionList) Evaluate(ctx context.Context, b Binder) (bool, error) {
+	v, _, err := cl.evaluateTrace(ctx, b)
+	return v, err
+}
+
+func (cl *conditionList) evaluateTrace(ctx context.Context, b Binder) (bool, string, error) {
+	name := "or"
+	if cl.all {
+		name = "and"
+	}
+	var traces []string
+	res := cl.all
+	var err error
+	for _, c := range cl.conds {
+		var v bool
+		var trace string
+		v, trace, err = evaluateCondition(ctx, c, b)
+		traces = append(traces, trace)
+		if err != nil || v != cl.all {
+			res = !cl.all
+			break
+		}
+	}
+	expr := fmt.Sprintf("%s(%s)", name, strings.Join(traces, ", "))
+	if err != nil {
+		return false, traceResult(expr, false, err), err
+	}
+	return res, traceResult(expr, res, nil), nil
+}
+
+// Deps is Condition.Deps.
+func (cl *conditionList) Deps() []ID {
+	var deps []ID
+	for _, c := range cl.conds {
+		deps = append(deps, c.Deps()...)
+	}
+	return deps
+}
+
+// Keys is Condition.Keys.
+func (cl *conditionList) Keys() []ReadOnlyKey[bool] {
+	var keys []ReadOnlyKey[bool]
+	for _, c := range cl.conds {
+		keys = append(keys, c.Keys()...)
+	}
+	return keys
+}
+
+func (cl *conditionList) describe() []KeyDescriptor {
+	var descs []KeyDescriptor
+	for _, c := range cl.conds {
+		descs = append(descs, describeCondition(c)...)
+	}
+	return descs
+}
+
+type notCondition struct {
+	cond Condition
+}
+
+// NotCond returns a Condition which negates the given condition. Errors from evaluating the given
+// condition are not negated.
+func NotCond(cond Condition) Condition {
+	return &notCondition{cond: cond}
+}
+
+// Evaluate is Condition.Evaluate.
+func (nc *notCondition) Evaluate(ctx context.Context, b Binder) (bool, error) {
+	v, _, err := nc.evaluateTrace(ctx, b)
+	return v, err
+}
+
+func (nc *notCondition) evaluateTrace(ctx context.Context, b Binder) (bool, string, error) {
+	v, trace, err := evaluateCondition(ctx, nc.cond, b)
+	return !v && err == nil, traceResult(fmt.Sprintf("not(%s)", trace), !v, err), err
+}
+
+// Deps is Condition.Deps.
+func (nc *notCondition) Deps() []ID {
+	return nc.cond.Deps()
+}
+
+// Keys is Condition.Keys.
+func (nc *notCondition) Keys() []ReadOnlyKey[bool] {
+	return nc.cond.Keys()
+}
+
+func (nc *notCondition) describe() []KeyDescriptor {
+	return describeCondition(nc.cond)
+}
+
+// keyCondition is the implementation of conditions on a single key of any type.
+type keyCondition[T any] struct {
+	key ReadOnlyKey[T]
+	// name is used in the trace, along with the key's ID.
+	name string
+	fn   func(b Binder) (bool, error)
+}
+
+// Evaluate is Condition.Evaluate.
+func (kc *keyCondition[T]) Evaluate(_ context.Context, b Binder) (bool, error) {
+	return kc.fn(b)
+}
+
+func (kc *keyCondition[T]) evaluateTrace(_ context.Context, b Binder) (bool, string, error) {
+	v, err := kc.fn(b)
+	return v, traceResult(fmt.Sprintf("%s(%s)", kc.name, kc.key.ID()), v, err), err
+}
+
+// Deps is Condition.Deps.
+func (kc *keyCondition[T]) Deps() []ID {
+	return keyDependencyIDs(kc.key)
+}
+
+// Keys is Condition.Keys. Conditions on keys which are not boolean have no boolean keys.
+func (kc *keyCondition[T]) Keys() []ReadOnlyKey[bool] {
+	return nil
+}
+
+func (kc *keyCondition[T]) describe() []KeyDescriptor {
+	return describeKey(kc.key)
+}
+
+// When returns a Condition which evaluates the predicate on the value bound to the key. It returns
+// an error if the key is not present.
+func When[T any](key ReadOnlyKey[T], predicate func(T) bool) Condition {
+	return &keyCondition[T]{
+		key:  key,
+		name: "when",
+		fn: func(b Binder) (bool, error) {
+			v, err := key.Get(b)
+			if err != nil {
+				return false, err
+			}
+			return predicate(v), nil
+		},
+	}
+}
+
+// IsPresent returns a Condition which evaluates to true if the key is bound to a value (i.e. not
+// bound as absent). For a VirtualKey, it evaluates to true if all of the keys which it reads are
+// bound to values.
+func IsPresent[T any](key ReadOnlyKey[T]) Condition {
+	return &keyCondition[T]{
+		key:  key,
+		name: "present",
+		fn: func(b Binder) (bool, error) {
+			return allPresent(b, keyDependencyIDs(key)), nil
+		},
+	}
+}
+
+// IsAbsentWith returns a Condition which evaluates to true if the key is bound as absent with an
+// error matching err (using errors.Is). For a VirtualKey, it evaluates to true if any of the keys
+// which it reads is bound as absent with a matching error.
+func IsAbsentWith[T any](key ReadOnlyKey[T], err error) Condition {
+	return &keyCondition[T]{
+		key:  key,
+		name: fmt.Sprintf("absent_with[%v]", err),
+		fn: func(b Binder) (bool, error) {
+			for _, id := range keyDependencyIDs(key) {
+				binding := b.Get(id)
+				if binding.Status() == Absent && errors.Is(binding.Error(), err) {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	}
+}
@@ -0,0 +1,123 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestConditions(t *testing.T) {
+	flag := NewKey[bool]("flag")
+	mode := NewKey[string]("mode")
+	missing := NewKey[int]("missing")
+	unbound := NewKey[bool]("unbound")
+	sentinelError := errors.New("sentinel error")
+
+	b := NewBinder()
+	if err := b.Store(
+		flag.Bind(true),
+		mode.Bind("prod"),
+		missing.BindError(sentinelError),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	isProd := When(mode, func(m string) bool { return m == "prod" })
+	for _, tc := range []struct {
+		cond      Condition
+		want      bool
+		wantTrace string
+		wantErr   bool
+	}{
+		{
+			cond:      And(ConditionAnd{flag}, isProd),
+			want:      true,
+			wantTrace: "and(and(flag=true)=true, when(mode)=true)=true",
+		},
+		{
+			cond: Or(NotCond(isProd), IsPresent(missing), IsAbsentWith(missing, sentinelError)),
+			want: true,
+			wantTrace: "or(not(when(mode)=true)=false, present(missing)=false, " +
+				"absent_with[sentinel error](missing)=true)=true",
+		},
+		{
+			// Evaluation stops at the first false condition, so unbound is never read.
+			cond:      And(NotCond(ConditionOr{flag}), ConditionAnd{unbound}),
+			want:      false,
+			wantTrace: "and(not(or(flag=true)=true)=false)=false",
+		},
+		{
+			cond: Or(When(missing, func(int) bool { return true })),
+			wantTrace: `or(when(missing)=error(cannot get key "missing": sentinel error))=` +
+				`error(cannot get key "missing": sentinel error)`,
+			wantErr: true,
+		},
+	} {
+		got, trace, err := evaluateCondition(context.Background(), tc.cond, b)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v; want error: %t", tc.wantTrace, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %t; want %t", tc.wantTrace, got, tc.want)
+		}
+		if trace != tc.wantTrace {
+			t.Errorf("got trace %s; want %s", trace, tc.wantTrace)
+		}
+	}
+
+	deps := And(ConditionAnd{flag}, Or(isProd, NotCond(IsPresent(missing)))).Deps()
+	if len(deps) != 3 || deps[0] != flag.ID() || deps[1] != mode.ID() || deps[2] != missing.ID() {
+		t.Errorf("got deps %v; want [flag mode missing]", deps)
+	}
+}
+
+func TestConditionsOnVirtualKeys(t *testing.T) {
+	first := NewKey[int]("first")
+	second := NewKey[int]("second")
+	sentinelError := errors.New("sentinel error")
+	sum := Combine2(first, second, func(a, b int) int { return a + b })
+
+	for _, tc := range []struct {
+		description string
+		bindings    []Binding
+		cond        Condition
+		want        bool
+	}{
+		{
+			description: "present if all underlying keys are present",
+			bindings:    []Binding{first.Bind(1), second.Bind(2)},
+			cond:        IsPresent(sum),
+			want:        true,
+		},
+		{
+			description: "not present if any underlying key is absent",
+			bindings:    []Binding{first.Bind(1), second.BindError(sentinelError)},
+			cond:        IsPresent(sum),
+			want:        false,
+		},
+		{
+			description: "absent with error of an underlying key",
+			bindings:    []Binding{first.Bind(1), second.BindError(sentinelError)},
+			cond:        IsAbsentWith(sum, sentinelError),
+			want:        true,
+		},
+		{
+			description: "mapped key present",
+			bindings:    []Binding{first.Bind(1)},
+			cond:        IsPresent(Mapped(first, func(i int) bool { return i > 0 })),
+			want:        true,
+		},
+	} {
+		b := NewBinder()
+		if err := b.Store(tc.bindings...); err != nil {
+			t.Fatal(err)
+		}
+		got, err := tc.cond.Evaluate(context.Background(), b)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.description, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %t; want %t", tc.description, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package taskgraph
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/sirupsen/logrus"
+)
+
+type runIDContextKey struct{}
+
+type taskContextKey struct{}
+
+// taskContext identifies the task for which a context was created.
+type taskContext struct {
+	name    string
+	attempt int
+}
+
+// WithRunID returns a context which causes runs of graphs started with it to use the given run ID,
+// rather than generating one.
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDContextKey{}, runID)
+}
+
+// RunIDFromContext returns the ID of the run in which the context was created (e.g. the context
+// passed to a task), or an empty string if it was not created by a run. Each run of a graph is
+// given a random ID, unless one is set with WithRunID; nested graphs (e.g. created with
+// Graph.AsTask) share the ID of the run of the outermost graph.
+//
+// The run ID is added to the spans of the run and its tasks, to the fields of the tasks' log
+// messages (see WithLogger), and to the DependencyViolations passed to StrictDependencies.Report.
+// Other callbacks (e.g. a CompensateFunc or PlanFunc) are not given it explicitly, but can read it
+// from their contexts.
+func RunIDFromContext(ctx context.Context) string {
+	runID, _ := ctx.Value(runIDContextKey{}).(string)
+	return runID
+}
+
+// TaskNameFromContext returns the name of the task to which the context was passed, or an empty
+// string if it was not passed to a task.
+func TaskNameFromContext(ctx context.Context) string {
+	tc, _ := ctx.Value(taskContextKey{}).(taskContext)
+	return tc.name
+}
+
+// AttemptFromContext returns the attempt at running the task to which the context was passed,
+// starting from 1, or 0 if it was not passed to a task.
+func AttemptFromContext(ctx context.Context) int {
+	tc, _ := ctx.Value(taskContextKey{}).(taskContext)
+	return tc.attempt
+}
+
+// GraphPathFromContext returns the names of the graphs in which the context was created, from the
+// outermost graph which was run to the graph containing the task to which the context was passed.
+func GraphPathFromContext(ctx context.Context) []string {
+	return append([]string{}, graphPath(ctx)...)
+}
+
+// withTask returns a context for running the named task.
+func withTask(ctx context.Context, name string, attempt int) context.Context {
+	return context.WithValue(ctx, taskContextKey{}, taskContext{name: name, attempt: attempt})
+}
+
+// withNewRunID returns a context with a new run ID, unless the context already has one.
+func withNewRunID(ctx context.Context) context.Context {
+	if RunIDFromContext(ctx) != "" {
+		return ctx
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return WithRunID(ctx, hex.EncodeToString(b))
+}
+
+// withLogFields returns a Logger which adds the given fields to each message, if the Logger
+// supports fields (as logrus loggers do), or the Logger itself otherwise.
+func withLogFields(logger Logger, fields map[string]any) Logger {
+	if fl, ok := logger.(interface {
+		WithFields(logrus.Fields) *logrus.Entry
+	}); ok {
+		return fl.WithFields(fields)
+	}
+	return logger
+}
@@ -0,0 +1,93 @@
+package taskgraph_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	tg "github.com/thought-machine/taskgraph"
+)
+
+type taskContextInfo struct {
+	RunID     string
+	Task      string
+	GraphPath []string
+	Attempt   int
+}
+
+func TestContextHelpers(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string]taskContextInfo{}
+	record := func(ctx context.Context, _ tg.Binder) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got[tg.TaskNameFromContext(ctx)] = taskContextInfo{
+			RunID:     tg.RunIDFromContext(ctx),
+			Task:      tg.TaskNameFromContext(ctx),
+			GraphPath: tg.GraphPathFromContext(ctx),
+			Attempt:   tg.AttemptFromContext(ctx),
+		}
+		return nil
+	}
+	inner, err := tg.New("inner", tg.WithTasks(tg.NoOutputTask("inner_task", record)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	innerTask, err := inner.AsTask()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetLevel(logrus.DebugLevel)
+	logger.SetOutput(&logs)
+	g, err := tg.New(
+		"outer",
+		tg.WithTasks(tg.NoOutputTask("outer_task", record), innerTask),
+		tg.WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Run(tg.WithRunID(context.Background(), "run-1")); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]taskContextInfo{
+		"outer_task": {RunID: "run-1", Task: "outer_task", GraphPath: []string{"outer"}, Attempt: 1},
+		"inner_task": {
+			RunID:     "run-1",
+			Task:      "inner_task",
+			GraphPath: []string{"outer", "inner"},
+			Attempt:   1,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("task contexts (-want +got):\n%s", diff)
+	}
+	if !strings.Contains(logs.String(), "run_id=run-1") ||
+		!strings.Contains(logs.String(), "task=outer_task") {
+		t.Errorf("expected logs to have run_id and task fields; got:\n%s", logs.String())
+	}
+
+	// Each run generates a new ID if one is not set.
+	if _, err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	first := got["outer_task"].RunID
+	if _, err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if second := got["outer_task"].RunID; first == "" || first == second {
+		t.Errorf("expected a new run ID for each run; got %q and %q", first, second)
+	}
+	if id := tg.RunIDFromContext(context.Background()); id != "" {
+		t.Errorf("got run ID %q outside of a run", id)
+	}
+}
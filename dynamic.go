@@ -0,0 +1,85 @@
+package taskgraph
+
+import (
+	"context"
+	"strings"
+
+	set "github.com/deckarep/golang-set/v2"
+)
+
+// Dynamic is a TaskSet containing a single task, which calls Build to create a set of tasks from
+// its dependencies at run time, and then runs those tasks as a nested graph (in the same way as a
+// task created by Graph.AsTask). This allows the shape of a workflow to depend on data produced by
+// earlier tasks. To run an existing Graph, Build can return the task produced by its AsTask method.
+//
+// The tasks returned by Build are validated in the same way as by New: it is an error for them to
+// contain cycles or duplicate provided keys. Any keys they depend on which they do not provide
+// must be included in Depends, and they must provide every key in Exposes. Only the bindings for
+// the keys in Exposes are available outside the nested graph.
+type Dynamic struct {
+	Name    string
+	Depends []ID
+	Exposes []ID
+	Build   func(ctx context.Context, b Binder) (TaskSet, error)
+
+	// Options are passed to New when creating the nested graph, after the tasks returned by Build
+	// (e.g. to pass WithLogger). If no WithTracer option is passed, spans are recorded using the
+	// tracer of the span in the task's context.
+	Options  []GraphOption
+	location string
+}
+
+// Locate annotates the Dynamic with its location in the source code, to make error messages easier
+// to understand. Calling it is recommended.
+func (d Dynamic) Locate() Dynamic {
+	d.location = getLocation(2)
+	return d
+}
+
+// Tasks satisfies TaskSet.Tasks.
+func (d Dynamic) Tasks() []Task {
+	return []Task{&task{
+		name:     d.Name,
+		depends:  d.Depends,
+		provides: d.Exposes,
+		fn:       d.execute,
+		location: d.location,
+	}}
+}
+
+func (d Dynamic) execute(ctx context.Context, b Binder) ([]Binding, error) {
+	ts, err := d.Build(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	opts := append(
+		[]GraphOption{WithTasks(ts), WithTracer(tracerFromContext(ctx))},
+		d.Options...,
+	)
+	g, err := New(d.Name, opts...)
+	if err != nil {
+		return nil, wrapStackErrorf("building dynamic graph %s: %w", d.Name, err)
+	}
+	t, err := g.AsTask(d.Exposes...)
+	if err != nil {
+		return nil, wrapStackErrorf("building dynamic graph %s: %w", d.Name, err)
+	}
+
+	// The nested graph can only read the keys which the task declared as dependencies, as others
+	// may not yet be bound.
+	undeclared := set.NewSet(t.Depends()...).Difference(set.NewSet(d.Depends...))
+	if undeclared.Cardinality() > 0 {
+		var missing []string
+		for id := range undeclared.Iter() {
+			missing = append(missing, id.String())
+		}
+		return nil, wrapStackErrorf(
+			"building dynamic graph %s: %w: %s",
+			d.Name,
+			ErrMissingInputs,
+			strings.Join(missing, ", "),
+		)
+	}
+
+	return t.Execute(ctx, b)
+}
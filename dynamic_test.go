@@ -0,0 +1,108 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestDynamic(t *testing.T) {
+	clusters := tg.NewKey[[]string]("clusters")
+	prefix := tg.NewKey[string]("prefix")
+	result := tg.NewKey[string]("result")
+	other := tg.NewKey[string]("other")
+	sentinelError := errors.New("sentinel error")
+
+	// Builds a chain of one task per cluster, each appending the cluster to the previous value.
+	buildChain := func(_ context.Context, b tg.Binder) (tg.TaskSet, error) {
+		names, err := clusters.Get(b)
+		if err != nil {
+			return nil, err
+		}
+		prev := tg.ReadOnlyKey[string](prefix)
+		var tasks []tg.TaskSet
+		for i, name := range names {
+			next := tg.NewKey[string](fmt.Sprintf("step_%d", i))
+			if i == len(names)-1 {
+				next = result
+			}
+			tasks = append(tasks, tg.SimpleTask1[string, string](
+				"step_"+name,
+				next,
+				func(_ context.Context, val string) (string, error) { return val + name, nil },
+				prev,
+			))
+			prev = next
+		}
+		return tg.NewTaskSet(tasks...), nil
+	}
+
+	tgt.Suite{
+		Tests: []tgt.Test{
+			{
+				Description: "runs built tasks",
+				Task: tg.Dynamic{
+					Name:    "dynamic",
+					Depends: []tg.ID{clusters.ID(), prefix.ID()},
+					Exposes: []tg.ID{result.ID()},
+					Build:   buildChain,
+				}.Locate(),
+				Inputs: []tg.Binding{clusters.Bind([]string{"a", "b", "c"}), prefix.Bind(">")},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(result.Bind(">abc")),
+				},
+				CheckExcessBindings: true,
+			},
+			{
+				Description: "undeclared input",
+				Task: tg.Dynamic{
+					Name:    "dynamic",
+					Depends: []tg.ID{clusters.ID()},
+					Exposes: []tg.ID{result.ID()},
+					Build:   buildChain,
+				}.Locate(),
+				Inputs:    []tg.Binding{clusters.Bind([]string{"a"}), prefix.Bind(">")},
+				WantError: tg.ErrMissingInputs,
+			},
+			{
+				Description: "exposed key not provided",
+				Task: tg.Dynamic{
+					Name:    "dynamic",
+					Depends: []tg.ID{clusters.ID(), prefix.ID()},
+					Exposes: []tg.ID{result.ID(), other.ID()},
+					Build:   buildChain,
+				}.Locate(),
+				Inputs:    []tg.Binding{clusters.Bind([]string{"a"}), prefix.Bind(">")},
+				WantError: tg.ErrExposedKeyNotProvided,
+			},
+			{
+				Description: "cycle",
+				Task: tg.Dynamic{
+					Name:    "dynamic",
+					Exposes: []tg.ID{result.ID()},
+					Build: func(_ context.Context, _ tg.Binder) (tg.TaskSet, error) {
+						return tg.NewTaskSet(
+							tg.SimpleTask1[string, string]("a", result, nil, other),
+							tg.SimpleTask1[string, string]("b", other, nil, result),
+						), nil
+					},
+				}.Locate(),
+				WantError: tg.ErrGraphCycle,
+			},
+			{
+				Description: "build error",
+				Task: tg.Dynamic{
+					Name: "dynamic",
+					Build: func(_ context.Context, _ tg.Binder) (tg.TaskSet, error) {
+						return nil, sentinelError
+					},
+				}.Locate(),
+				WantError: sentinelError,
+			},
+		},
+	}.Run(t)
+}
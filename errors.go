@@ -0,0 +1,310 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	stackerrors "github.com/go-errors/errors"
+)
+
+// A TaskError is returned (wrapped in a RunError) when a task in a graph fails.
+//
+// Formatting a TaskError with %v produces a compact message qualified by the path to the task which
+// caused the failure (see Innermost), and %+v produces a verbose message which also includes the
+// location of the task and the stack (see Frames).
+type TaskError struct {
+	// Task is the name of the task.
+	Task string
+	// Location is the location of the task.
+	Location string
+	// GraphPath contains the names of the graphs containing the task, from the outermost graph
+	// which was run to the graph containing the task (e.g. a graph run as a task with AsTask).
+	GraphPath []string
+	// Attempt is the attempt at running the task which failed, starting from 1.
+	Attempt int
+	// Err is the error returned by the task.
+	Err error
+	// Cancelled is set if the task failed because the run was cancelled after another task had
+	// failed, rather than being a root cause of the run failing.
+	Cancelled bool
+
+	// stack is the stack of the error returned by the task, or the user's function from which the
+	// task was built if the error does not have one (see userFnStack).
+	stack []uintptr
+}
+
+// Error satisfies the error interface.
+func (e *TaskError) Error() string {
+	// Err is not formatted with %v, as that is compact for nested TaskErrors.
+	return "task " + e.Task + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error returned by the task.
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// Path returns the names of the graphs containing the task, followed by the name of the task.
+func (e *TaskError) Path() []string {
+	return append(append([]string{}, e.GraphPath...), e.Task)
+}
+
+// Innermost returns the TaskError of the task which caused this task to fail: for a task created
+// with Graph.AsTask, this is the (innermost) error of the first task in the nested graph which
+// failed; for other tasks, it is e.
+func (e *TaskError) Innermost() *TaskError {
+	inner := e
+	for {
+		var next *TaskError
+		if !errors.As(inner.Err, &next) {
+			return inner
+		}
+		inner = next
+	}
+}
+
+// Frames returns the frames of the stack of the error returned by the task (e.g. the stack at the
+// point where a key which was not bound was read), excluding frames within this package. If the
+// error does not have a stack, Frames returns a single frame for the function which returned it:
+// the function from which the task was built (e.g. passed to SimpleTask or Reflect), or the
+// Execute method of tasks implemented by other types. The line of the frame is that of the start
+// of the function.
+func (e *TaskError) Frames() []runtime.Frame {
+	var res []runtime.Frame
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		frame, more := frames.Next()
+		if isUserFrame(frame) {
+			res = append(res, frame)
+		}
+		if !more {
+			return res
+		}
+	}
+}
+
+// isUserFrame returns whether the stack frame is outside this package and the runtime.
+func isUserFrame(frame runtime.Frame) bool {
+	for _, prefix := range []string{
+		"github.com/thought-machine/taskgraph.",
+		"golang.org/x/sync/",
+		"runtime.",
+		"testing.",
+	} {
+		if strings.HasPrefix(frame.Function, prefix) {
+			return false
+		}
+	}
+	return frame.Function != ""
+}
+
+// Format satisfies fmt.Formatter.
+func (e *TaskError) Format(s fmt.State, verb rune) {
+	inner := e.Innermost()
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		fmt.Fprintf(s, "task %s: %v\n", strings.Join(inner.Path(), " > "), inner.Err)
+		fmt.Fprintf(s, "  location: %s", inner.Location)
+		if frames := inner.Frames(); len(frames) > 0 {
+			fmt.Fprint(s, "\n  stack:")
+			for _, frame := range frames {
+				fmt.Fprintf(s, "\n    %s\n        %s:%d", frame.Function, frame.File, frame.Line)
+			}
+		}
+	case verb == 'v':
+		fmt.Fprintf(
+			s,
+			"task %s (%s): %v",
+			strings.Join(inner.Path(), " > "),
+			filepath.Base(inner.Location),
+			inner.Err,
+		)
+	default:
+		fmt.Fprintf(s, fmt.FormatString(s, verb), e.Error())
+	}
+}
+
+// userFnStack returns a stack containing only the user's function from which the task was built,
+// for errors returned by the task without a stack: the function has returned by the time the error
+// is seen, and the rest of the stack is within this package.
+func userFnStack(t Task) []uintptr {
+	fn := executeMethod(t)
+	if tt, ok := t.(*task); ok {
+		fn = tt.userFn
+	}
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil
+	}
+	// Frames treats the stack as return addresses, which are after the call instruction.
+	return []uintptr{v.Pointer() + 1}
+}
+
+// executeMethod returns the Execute method of the task's type (taking the task as its first
+// argument), or nil if it is not a named type with an Execute method.
+func executeMethod(t Task) any {
+	m, ok := reflect.TypeOf(t).MethodByName("Execute")
+	if !ok {
+		return nil
+	}
+	return m.Func.Interface()
+}
+
+// newTaskError returns a TaskError for an error returned while executing the task.
+func (gn *graphNode) newTaskError(ctx context.Context, err error) *TaskError {
+	var stack []uintptr
+	if se := new(stackerrors.Error); errors.As(err, &se) {
+		stack = se.Callers()
+	} else {
+		stack = userFnStack(gn.task)
+	}
+	return &TaskError{
+		Task:      gn.task.Name(),
+		Location:  gn.task.Location(),
+		GraphPath: graphPath(ctx),
+		Attempt:   max(AttemptFromContext(ctx), 1),
+		Err:       err,
+		stack:     stack,
+	}
+}
+
+// A RunError is returned when any of the tasks in a graph fail.
+type RunError struct {
+	// Graph is the name of the graph.
+	Graph string
+	// Errors contains the errors of every task which failed, in the order in which they failed.
+	Errors []*TaskError
+	// Cause, if set, is the error which stopped the run other than the task errors (e.g. the error
+	// of the run's context).
+	Cause error
+}
+
+// RootCauses returns the errors of the tasks which caused the run to fail, excluding those of tasks
+// which were cancelled as a consequence.
+func (e *RunError) RootCauses() []*TaskError {
+	var res []*TaskError
+	for _, te := range e.Errors {
+		if !te.Cancelled {
+			res = append(res, te)
+		}
+	}
+	return res
+}
+
+// Error satisfies the error interface. It describes the root causes of the run failing; if there is
+// a single root cause, it is the error of that task.
+func (e *RunError) Error() string {
+	var msgs []string
+	for _, te := range e.RootCauses() {
+		msgs = append(msgs, te.Error())
+	}
+	if e.Cause != nil {
+		msgs = append(msgs, e.Cause.Error())
+	}
+	msg := strings.Join(msgs, "; ")
+	if cancelled := len(e.Errors) - len(e.RootCauses()); cancelled > 0 {
+		msg += fmt.Sprintf(" (%d other task(s) cancelled)", cancelled)
+	}
+	return msg
+}
+
+// Format satisfies fmt.Formatter. With %v and %+v, each of the root causes is formatted in the same
+// way as a TaskError.
+func (e *RunError) Format(s fmt.State, verb rune) {
+	if verb != 'v' {
+		fmt.Fprintf(s, fmt.FormatString(s, verb), e.Error())
+		return
+	}
+	sep := "; "
+	if s.Flag('+') {
+		sep = "\n"
+	}
+	for i, te := range e.RootCauses() {
+		if i > 0 {
+			_, _ = io.WriteString(s, sep)
+		}
+		te.Format(s, verb)
+	}
+	if e.Cause != nil {
+		if len(e.RootCauses()) > 0 {
+			_, _ = io.WriteString(s, sep)
+		}
+		_, _ = io.WriteString(s, e.Cause.Error())
+	}
+	if cancelled := len(e.Errors) - len(e.RootCauses()); cancelled > 0 {
+		fmt.Fprintf(s, " (%d other task(s) cancelled)", cancelled)
+	}
+}
+
+// Unwrap returns the errors of all of the tasks, followed by the Cause (if set).
+func (e *RunError) Unwrap() []error {
+	var res []error
+	for _, te := range e.Errors {
+		res = append(res, te)
+	}
+	if e.Cause != nil {
+		res = append(res, e.Cause)
+	}
+	return res
+}
+
+// taskErrors collects the errors of the tasks in a run, in the order in which they failed.
+type taskErrors struct {
+	// Protects against concurrent access to errs
+	sync.Mutex
+
+	errs []*TaskError
+}
+
+// record records the error returned by a task run with the given context, marking it as cancelled
+// if the task failed because the context had been cancelled by the failure of another task.
+//
+// The context of the tasks in a run is cancelled with the TaskError of the first task to fail as
+// its cause (which may be a task in an outer graph, for a graph run with AsTask).
+func (te *taskErrors) record(ctx context.Context, err *TaskError) {
+	te.Lock()
+	defer te.Unlock()
+
+	var cause *TaskError
+	if errors.Is(err.Err, context.Canceled) && errors.As(context.Cause(ctx), &cause) {
+		err.Cancelled = cause != err
+	}
+	te.errs = append(te.errs, err)
+}
+
+// runError returns a RunError for the recorded task errors and cause, or cause if no tasks failed.
+func (te *taskErrors) runError(graph string, cause error) error {
+	te.Lock()
+	defer te.Unlock()
+
+	if len(te.errs) == 0 {
+		return cause
+	}
+	return &RunError{
+		Graph:  graph,
+		Errors: append([]*TaskError{}, te.errs...),
+		Cause:  cause,
+	}
+}
+
+type graphPathContextKey struct{}
+
+// withGraphPath returns a context for running the named graph within the graph(s) in which the
+// given context was created.
+func withGraphPath(ctx context.Context, name string) context.Context {
+	path := append(append([]string{}, graphPath(ctx)...), name)
+	return context.WithValue(ctx, graphPathContextKey{}, path)
+}
+
+// graphPath returns the names of the graphs in which the context was created, from the outermost.
+func graphPath(ctx context.Context) []string {
+	path, _ := ctx.Value(graphPathContextKey{}).([]string)
+	return path
+}
@@ -0,0 +1,318 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestRunErrorAggregatesTaskErrors(t *testing.T) {
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	// Both tasks wait until the other has started, so that they fail concurrently.
+	var started sync.WaitGroup
+	started.Add(2)
+	failAfterStart := func(err error) func(context.Context, tg.Binder) error {
+		return func(context.Context, tg.Binder) error {
+			started.Done()
+			started.Wait()
+			return err
+		}
+	}
+	g, err := tg.New("errors", tg.WithTasks(
+		tg.NoOutputTask("a", failAfterStart(errA)),
+		tg.NoOutputTask("b", failAfterStart(errB)),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = g.Run(context.Background())
+	var runErr *tg.RunError
+	if !errors.As(err, &runErr) {
+		t.Fatalf("expected RunError; got %T: %v", err, err)
+	}
+	if len(runErr.Errors) != 2 || len(runErr.RootCauses()) != 2 {
+		t.Fatalf("expected 2 root causes; got %v", runErr.Errors)
+	}
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("expected error to wrap both task errors; got %v", err)
+	}
+	for _, want := range []string{"task a: a failed", "task b: b failed"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q; got %v", want, err)
+		}
+	}
+}
+
+func TestTaskErrorCancelled(t *testing.T) {
+	errA := errors.New("a failed")
+	waitForCancellation := func(started *sync.WaitGroup) func(context.Context, tg.Binder) error {
+		return func(ctx context.Context, _ tg.Binder) error {
+			started.Done()
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}
+
+	for _, test := range []struct {
+		description string
+		// tasks returns the tasks of the graph, and whether the caller cancels the run once they
+		// have started.
+		tasks         func(started *sync.WaitGroup) ([]tg.TaskSet, bool)
+		wantTasks     []string
+		wantCancelled []string
+		wantError     string
+	}{
+		{
+			description: "after another task failed",
+			tasks: func(started *sync.WaitGroup) ([]tg.TaskSet, bool) {
+				started.Add(1)
+				return []tg.TaskSet{
+					tg.NoOutputTask("a", func(context.Context, tg.Binder) error {
+						started.Wait()
+						return errA
+					}),
+					tg.NoOutputTask("b", waitForCancellation(started)),
+				}, false
+			},
+			wantTasks:     []string{"a", "b"},
+			wantCancelled: []string{"b"},
+			wantError:     "task a: a failed (1 other task(s) cancelled)",
+		},
+		{
+			// The tasks were cancelled by the caller rather than by a task failing.
+			description: "by the caller",
+			tasks: func(started *sync.WaitGroup) ([]tg.TaskSet, bool) {
+				started.Add(2)
+				return []tg.TaskSet{
+					tg.NoOutputTask("a", waitForCancellation(started)),
+					tg.NoOutputTask("b", waitForCancellation(started)),
+					// Makes the run wait for the cancelled tasks to return before it returns.
+					tg.Finally{
+						Wrapped: tg.NoOutputTask("finally", func(context.Context, tg.Binder) error {
+							return nil
+						}),
+					}.Locate(),
+				}, true
+			},
+			wantTasks: []string{"a", "b"},
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			var started sync.WaitGroup
+			tasks, cancelRun := test.tasks(&started)
+			g, err := tg.New("errors", tg.WithTasks(tasks...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if cancelRun {
+				go func() {
+					started.Wait()
+					cancel()
+				}()
+			}
+			_, err = g.Run(ctx)
+			var runErr *tg.RunError
+			if !errors.As(err, &runErr) {
+				t.Fatalf("expected RunError; got %T: %v", err, err)
+			}
+			var gotTasks, gotCancelled []string
+			for _, te := range runErr.Errors {
+				gotTasks = append(gotTasks, te.Task)
+				if te.Cancelled {
+					gotCancelled = append(gotCancelled, te.Task)
+				}
+			}
+			sort.Strings(gotTasks)
+			if diff := cmp.Diff(test.wantTasks, gotTasks); diff != "" {
+				t.Errorf("task errors (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.wantCancelled, gotCancelled); diff != "" {
+				t.Errorf("cancelled tasks (-want +got):\n%s", diff)
+			}
+			if test.wantError != "" && err.Error() != test.wantError {
+				t.Errorf("got %q; want %q", err.Error(), test.wantError)
+			}
+		})
+	}
+}
+
+func TestTaskErrorNested(t *testing.T) {
+	errInner := errors.New("inner failed")
+	inner, err := tg.New("inner", tg.WithTasks(
+		tg.NoOutputTask("fail", func(context.Context, tg.Binder) error { return errInner }),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	innerTask, err := inner.AsTask()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := tg.New("outer", tg.WithTasks(innerTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = g.Run(context.Background())
+	var te *tg.TaskError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected TaskError; got %T: %v", err, err)
+	}
+	if te.Task != "inner" || te.Attempt != 1 || !strings.Contains(te.Location, "errors_test.go:") {
+		t.Errorf("unexpected outer task error: %+v", te)
+	}
+	if diff := cmp.Diff([]string{"outer"}, te.GraphPath); diff != "" {
+		t.Errorf("outer graph path (-want +got):\n%s", diff)
+	}
+
+	var innerRunErr *tg.RunError
+	if !errors.As(te.Err, &innerRunErr) || len(innerRunErr.Errors) != 1 {
+		t.Fatalf("expected inner RunError; got %v", te.Err)
+	}
+	innerErr := innerRunErr.Errors[0]
+	if diff := cmp.Diff([]string{"outer", "inner"}, innerErr.GraphPath); diff != "" {
+		t.Errorf("inner graph path (-want +got):\n%s", diff)
+	}
+	if !errors.Is(innerErr, errInner) {
+		t.Errorf("got %v; want %v", innerErr, errInner)
+	}
+}
+
+func TestTaskErrorFormat(t *testing.T) {
+	keyMissing := tg.NewKey[string]("errors_missing")
+	inner, err := tg.New("inner", tg.WithTasks(
+		tg.NoOutputTask("read", func(_ context.Context, b tg.Binder) error {
+			// The key is bound as absent, so the error's stack points here.
+			_, err := keyMissing.Get(b)
+			return err
+		}, keyMissing.ID()),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	innerTask, err := inner.AsTask()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := tg.New("outer", tg.WithTasks(innerTask))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = g.Run(context.Background(), keyMissing.BindAbsent())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if want := "task inner: task read: cannot get key"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got %q; want prefix %q", err.Error(), want)
+	}
+	compact := fmt.Sprintf("%v", err)
+	if want := "task outer > inner > read (errors_test.go:"; !strings.HasPrefix(compact, want) {
+		t.Errorf("got %q; want prefix %q", compact, want)
+	}
+	verbose := fmt.Sprintf("%+v", err)
+	for _, want := range []string{
+		"task outer > inner > read: cannot get key",
+		"location: ",
+		"stack:",
+		"TestTaskErrorFormat",
+	} {
+		if !strings.Contains(verbose, want) {
+			t.Errorf("expected verbose error to contain %q; got:\n%s", want, verbose)
+		}
+	}
+	if strings.Contains(verbose, "thought-machine/taskgraph.") {
+		t.Errorf("expected stack to exclude frames within taskgraph; got:\n%s", verbose)
+	}
+}
+
+// failingTask is a Task implemented without the helpers in taskgraph.
+type failingTask struct {
+	err error
+}
+
+func (ft failingTask) Tasks() []tg.Task { return []tg.Task{ft} }
+func (failingTask) Name() string        { return "custom" }
+func (failingTask) Location() string    { return "errors_test.go" }
+func (failingTask) Depends() []tg.ID    { return nil }
+func (failingTask) Provides() []tg.ID   { return nil }
+
+func (ft failingTask) Execute(context.Context, tg.Binder) ([]tg.Binding, error) {
+	return nil, ft.err
+}
+
+func TestTaskErrorFramesWithoutStack(t *testing.T) {
+	keyA := tg.NewKey[string]("a")
+	errFail := errors.New("failed")
+
+	for _, test := range []struct {
+		description  string
+		task         tg.TaskSet
+		wantFunction string
+	}{
+		{
+			description: "SimpleTask",
+			task: tg.SimpleTask[string]("a", keyA, func(context.Context, tg.Binder) (string, error) {
+				return "", errFail
+			}),
+			wantFunction: "TestTaskErrorFramesWithoutStack.func",
+		},
+		{
+			description: "Reflect",
+			task: tg.Reflect[string]{
+				Name:      "a",
+				ResultKey: keyA,
+				Fn:        func() (string, error) { return "", errFail },
+			}.Locate(),
+			wantFunction: "TestTaskErrorFramesWithoutStack.func",
+		},
+		{
+			description: "wrapped",
+			task: tg.Compensated{
+				Wrapped: tg.NoOutputTask("a", func(context.Context, tg.Binder) error {
+					return errFail
+				}),
+				Compensate: func(context.Context, tg.Binder) error { return nil },
+			}.Locate(),
+			wantFunction: "TestTaskErrorFramesWithoutStack.func",
+		},
+		{
+			description:  "custom task",
+			task:         failingTask{err: errFail},
+			wantFunction: "failingTask.Execute",
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(test.task)))
+			_, err := g.Run(context.Background())
+			var te *tg.TaskError
+			if !errors.As(err, &te) {
+				t.Fatalf("expected TaskError; got %T: %v", err, err)
+			}
+			frames := te.Frames()
+			if len(frames) != 1 ||
+				!strings.Contains(frames[0].Function, test.wantFunction) ||
+				filepath.Base(frames[0].File) != "errors_test.go" {
+				t.Fatalf("got frames %+v; want a frame for %s", frames, test.wantFunction)
+			}
+			verbose := fmt.Sprintf("%+v", err)
+			if want := fmt.Sprintf("errors_test.go:%d", frames[0].Line); !strings.Contains(verbose, want) {
+				t.Errorf("expected verbose error to contain %q; got:\n%s", want, verbose)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrFinallyProvidesKeys is returned by New if a task wrapped in a Finally provides keys, as no
+// other task could depend on them.
+var ErrFinallyProvidesKeys = errors.New("finally tasks must not provide keys")
+
+// RunResult describes the outcome of the tasks of a graph, for tasks wrapped in a Finally.
+type RunResult struct {
+	// Err is the error which caused the run to fail, or nil if all of the tasks succeeded.
+	Err error
+}
+
+// RunResultKey is bound to the RunResult of the graph in the Binder passed to tasks wrapped in a
+// Finally. It is not bound for any other tasks.
+var RunResultKey = NewNamespacedKey[RunResult]("taskgraph", "run_result")
+
+// Finally is a TaskSet which wraps tasks that always run once all of the other tasks in the graph
+// have finished or been cancelled, even if the run failed, such as tasks which report the status of
+// the run. The tasks must not provide any keys.
+//
+// The tasks' dependencies need not be bound when they are run: keys which were not bound by the
+// run are Pending (or Absent, if they were bound as absent). RunResultKey is bound to the outcome
+// of the run. The tasks are run one at a time, in order, with a context which is not cancelled when
+// the run's context is cancelled, so that cleanup is not cut off. Errors returned by the tasks are
+// joined with the run's error.
+//
+// Graph.Plan does not run the tasks (unless they are wrapped in a SideEffect, whose plan functions
+// are called), but plans them after the other tasks.
+//
+// Graph.Graphviz draws the tasks, and the edges from the tasks they depend on, dashed.
+type Finally struct {
+	Wrapped TaskSet
+
+	// Timeout, if positive, is the timeout of the context passed to each task.
+	Timeout  time.Duration
+	location string
+}
+
+// Locate annotates the Finally with its location in the source code, to make error messages easier
+// to understand. Calling it is recommended.
+func (f Finally) Locate() Finally {
+	f.location = getLocation(2)
+	return f
+}
+
+// finallyOptions is set for tasks wrapped in a Finally.
+type finallyOptions struct {
+	timeout time.Duration
+}
+
+// Tasks satisfies TaskSet.Tasks.
+func (f Finally) Tasks() []Task {
+	var res []Task
+	for _, t := range f.Wrapped.Tasks() {
+		ft := copyTask(t)
+		if f.location != "" {
+			ft.location = f.location
+		}
+		ft.finally = &finallyOptions{timeout: f.Timeout}
+		res = append(res, ft)
+	}
+	return res
+}
+
+// finallyOptionsOf returns the finallyOptions of the task, or nil if it is not wrapped in a
+// Finally.
+func finallyOptionsOf(t Task) *finallyOptions {
+	if tt, ok := t.(*task); ok {
+		return tt.finally
+	}
+	return nil
+}
+
+const (
+	traceTaskgraphFinallyPrefix = "taskgraph.finally."
+)
+
+// runFinally runs the graph's finally tasks once the other tasks have terminated with the given
+// error, returning the errors returned by any of them.
+func (g *graph) runFinally(ctx context.Context, b Binder, runErr error) error {
+	ctx = context.WithoutCancel(ctx)
+	result := NewBinder()
+	if err := result.Store(RunResultKey.Bind(RunResult{Err: runErr})); err != nil {
+		return err
+	}
+	b = NewOverlayBinder(b, result)
+
+	var errs error
+	for _, gn := range g.finally {
+		if err := gn.executeFinally(ctx, b); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+// executeFinally executes a task wrapped in a Finally.
+func (gn *graphNode) executeFinally(ctx context.Context, b Binder) error {
+	if timeout := finallyOptionsOf(gn.task).timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx = withTask(ctx, gn.task.Name(), 1)
+	ctx = withPlanPosition(ctx, gn.index)
+	if planUnmarkedSideEffect(ctx, gn.task) {
+		return nil
+	}
+	ctx, span := gn.tracer.Start(ctx, gn.task.Name())
+	defer span.End()
+	span.SetAttributes(
+		attribute.Bool(traceTaskgraphFinallyPrefix+"task", true),
+		attribute.String(traceTaskgraphPrefix+"run_id", RunIDFromContext(ctx)),
+	)
+
+	logger := withLogFields(gn.logger, map[string]any{
+		"run_id": RunIDFromContext(ctx),
+		"task":   gn.task.Name(),
+	})
+	logger.Debugf("Starting finally task %s", gn.task.Name())
+	defer logger.Debugf("Finished finally task %s", gn.task.Name())
+
+	if _, err := gn.task.Execute(ctx, b); err != nil {
+		span.RecordError(err)
+		return gn.newTaskError(ctx, err)
+	}
+	return nil
+}
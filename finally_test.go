@@ -0,0 +1,165 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestFinally(t *testing.T) {
+	keyA := tg.NewKey[string]("a")
+	keyB := tg.NewKey[string]("b")
+	errB := errors.New("failed")
+
+	for _, test := range []struct {
+		description string
+		errB        error
+		wantStatusB tg.BindStatus
+	}{
+		{
+			description: "successful run",
+			wantStatusB: tg.Present,
+		},
+		{
+			description: "failed run",
+			errB:        errB,
+			// Keys which were not bound by the run are pending.
+			wantStatusB: tg.Pending,
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			var runErr, ctxErr error
+			var statusA, statusB tg.BindStatus
+			var hasDeadline bool
+			g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(
+				tg.SimpleTask[string]("a", keyA, func(context.Context, tg.Binder) (string, error) {
+					return "a", nil
+				}),
+				tg.SimpleTask1[string, string]("b", keyB, func(context.Context, string) (string, error) {
+					return "b", test.errB
+				}, keyA),
+				tg.Finally{
+					Wrapped: tg.NoOutputTask("report", func(ctx context.Context, b tg.Binder) error {
+						result, err := tg.RunResultKey.Get(b)
+						if err != nil {
+							return err
+						}
+						runErr = result.Err
+						statusA, statusB = b.Get(keyA.ID()).Status(), b.Get(keyB.ID()).Status()
+						ctxErr = ctx.Err()
+						_, hasDeadline = ctx.Deadline()
+						return nil
+					}, keyA.ID(), keyB.ID(), tg.RunResultKey.ID()),
+					Timeout: time.Minute,
+				}.Locate(),
+			)))
+
+			if err := g.Check(); err != nil {
+				t.Errorf("expected RunResultKey not to be a graph input; got %v", err)
+			}
+			// The run's context is cancelled when a task fails, but the finally task's is not.
+			if _, err := g.Run(context.Background()); !errors.Is(err, test.errB) {
+				t.Fatalf("got error %v; want %v", err, test.errB)
+			}
+			if !errors.Is(runErr, test.errB) {
+				t.Errorf("got run error %v; want %v", runErr, test.errB)
+			}
+			if statusA != tg.Present || statusB != test.wantStatusB {
+				t.Errorf(
+					"got statuses (%v, %v); want (%v, %v)",
+					statusA,
+					statusB,
+					tg.Present,
+					test.wantStatusB,
+				)
+			}
+			if ctxErr != nil {
+				t.Errorf("expected finally task's context not to be cancelled; got %v", ctxErr)
+			}
+			if !hasDeadline {
+				t.Error("expected finally task's context to have a deadline")
+			}
+		})
+	}
+}
+
+func TestFinallyAsTask(t *testing.T) {
+	keyA := tg.NewKey[string]("a")
+	keyB := tg.NewKey[string]("b")
+	errB := errors.New("failed")
+
+	var statusB tg.BindStatus
+	inner := tgt.Must[tg.Graph](t)(tg.New("inner", tg.WithTasks(
+		tg.SimpleTask[string]("a", keyA, func(context.Context, tg.Binder) (string, error) {
+			return "a", nil
+		}),
+		tg.SimpleTask1[string, string]("b", keyB, func(context.Context, string) (string, error) {
+			return "", errB
+		}, keyA),
+		tg.Finally{
+			Wrapped: tg.NoOutputTask("report", func(_ context.Context, b tg.Binder) error {
+				statusB = b.Get(keyB.ID()).Status()
+				return nil
+			}, keyB.ID()),
+		}.Locate(),
+	)))
+
+	// The keys bound within the nested graph are not read from the Binder of the task, even if they
+	// are pending.
+	tgt.Test{
+		Task:               tgt.Must[tg.Task](t)(inner.AsTask()),
+		WantError:          errB,
+		StrictDependencies: true,
+	}.Run(t)
+	if statusB != tg.Pending {
+		t.Errorf("got status %v; want %v", statusB, tg.Pending)
+	}
+}
+
+func TestFinallyGraphviz(t *testing.T) {
+	keyA := tg.NewKey[string]("a")
+	keyIn := tg.NewKey[string]("in")
+
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(
+		tg.SimpleTask[string]("a", keyA, func(context.Context, tg.Binder) (string, error) {
+			return "a", nil
+		}),
+		tg.Finally{
+			Wrapped: tg.NoOutputTask("report", func(context.Context, tg.Binder) error {
+				return nil
+			}, keyA.ID(), keyIn.ID(), tg.RunResultKey.ID()),
+		}.Locate(),
+	)))
+
+	graphviz := g.Graphviz(true)
+	for _, want := range []string{
+		`report [label="Finally - report", style=dashed];`,
+		`a -> report [label="a", style=dashed];`,
+		`report_input_in -> report [style=dashed];`,
+	} {
+		if !strings.Contains(graphviz, want) {
+			t.Errorf("expected graphviz to contain %s; got:\n%s", want, graphviz)
+		}
+	}
+	if strings.Contains(graphviz, "run_result") {
+		t.Errorf("expected graphviz not to contain RunResultKey; got:\n%s", graphviz)
+	}
+}
+
+func TestFinallyProvidesKeys(t *testing.T) {
+	keyA := tg.NewKey[string]("a")
+
+	_, err := tg.New("test_graph", tg.WithTasks(tg.Finally{
+		Wrapped: tg.SimpleTask[string]("a", keyA, func(context.Context, tg.Binder) (string, error) {
+			return "a", nil
+		}),
+	}.Locate()))
+	if !errors.Is(err, tg.ErrFinallyProvidesKeys) {
+		t.Errorf("got %v; want %v", err, tg.ErrFinallyProvidesKeys)
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime/debug"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -32,6 +35,11 @@ var (
 	// ErrDuplicateProvidedKeys is returned from New() if multiple tasks provide the same key.
 	ErrDuplicateProvidedKeys = errors.New("keys provided by multiple tasks")
 
+	// ErrKeyTypeConflict is returned from New() if keys with the same ID but different types are used
+	// by tasks in the graph (including tasks within graphs composed using AsTask()). Such keys would
+	// otherwise result in ErrWrongType being returned when the graph is run.
+	ErrKeyTypeConflict = errors.New("keys with the same ID have different types")
+
 	// ErrGraphCycle is returned from New() if there is a cycle in the graph tasks (i.e. if a task A
 	// depends on a key which is produced by some task B which depends indirectly on a key produced by
 	// task A).
@@ -42,6 +50,10 @@ var (
 	// checking is optimised.
 	ErrTooManyTasks = wrapStackErrorf("too many tasks in graph (limit %d)", taskLimit)
 
+	// ErrRemappedKeyNotUsed is returned by Graph.Instantiate if an ID to be remapped is not used by
+	// any task in the graph.
+	ErrRemappedKeyNotUsed = errors.New("remapped key(s) not used by graph")
+
 	// ErrMissingInputs is returned from Graph.Run() if the provided inputs do not satisfy all of the
 	// graph's dependencies (i.e. all task dependencies that are not provided by some other task in
 	// the graph).
@@ -54,7 +66,8 @@ type Graph interface {
 	//
 	// This is intended to be run by a genrule at build time to assert that all keys required by tasks
 	// in the graph are provided either as an input or by some other task in the graph. It also checks
-	// that there are no duplicate inputs.
+	// that there are no duplicate inputs. Inputs with defaults (see WithInputDefault and
+	// WithOptionalInput) need not be passed.
 	Check(inputs ...Binding) error
 
 	// Run executes the task graph with the given inputs, returning a Binder containing the bound
@@ -64,22 +77,71 @@ type Graph interface {
 	// tasks to listen for context cancellation.
 	Run(ctx context.Context, inputs ...Binding) (Binder, error)
 
+	// RunOutputs executes the task graph like Run, but returns a Binder containing only the bindings
+	// for the outputs declared with WithOutputs, rather than leaking the intermediate bindings. If
+	// the graph was created with WithEarlyStop, the run stops as soon as the outputs are bound and
+	// no side-effecting tasks remain. If the graph has no declared outputs, it returns ErrNoOutputs
+	// without running any tasks.
+	RunOutputs(ctx context.Context, inputs ...Binding) (Binder, error)
+
+	// RunShared executes the task graph like Run, except that concurrent calls with the same key
+	// share a single run: calls made while a run with the key is in progress wait for that run
+	// rather than starting another, and all of them receive the same Binder (which must not be
+	// modified) and error. The key should therefore identify the inputs, which are ignored when
+	// joining a run in progress; the run uses the values of the context of the call which started
+	// it.
+	//
+	// A call whose context is cancelled returns immediately with the context's error, but the run is
+	// only cancelled once the contexts of all of the calls sharing it have been cancelled.
+	RunShared(ctx context.Context, key string, inputs ...Binding) (Binder, error)
+
+	// Plan performs a dry run of the task graph with the given inputs, to preview what a run would
+	// do. Pure tasks are run as normal, but side-effecting tasks (see SideEffect) are not; their plan
+	// functions are called instead, and the returned PlanReport lists the actions which would be
+	// taken.
+	Plan(ctx context.Context, inputs ...Binding) (*PlanReport, error)
+
+	// Resume executes the task graph like Run, but saves the progress of the run to the given
+	// Checkpointer as each task completes. Any progress previously saved to the Checkpointer is
+	// loaded first: the bindings from the checkpoint are preloaded into the graph's Binder (and are
+	// included in the returned Binder), and tasks whose provided keys are all already bound are not
+	// executed again (nor are tasks which provide no keys, if they completed previously).
+	//
+	// The inputs should be the same as those passed when the checkpoint was created. To start a run
+	// which can later be resumed, call Resume with an empty Checkpointer.
+	Resume(ctx context.Context, checkpointer Checkpointer, inputs ...Binding) (Binder, error)
+
 	// AsTask produces a Task which runs this Graph in full to allow composition of graphs. The task
 	// depends on all keys which are required by any task within it and not provided by any task
-	// within it. The task provides only the key IDs passed to this method; and only their bindings
-	// will be available in the result of any graph the task is included in (any bindings produced by
-	// tasks within this graph whose IDs were not passed to this method will be suppressed).
+	// within it, except for inputs with defaults. The task provides only the key IDs passed to this
+	// method; and only their bindings will be available in the result of any graph the task is
+	// included in (any bindings produced by tasks within this graph whose IDs were not passed to this
+	// method will be suppressed).
 	//
 	// Bindings for the exposed keys are added to the binder of the parent task as soon as they are
 	// generated by tasks within this graph, which means that tasks outside this graph which depend on
 	// the exposed keys can start running as soon as the producing task completes, rather than waiting
 	// for this entire task to complete.
+	//
+	// The location of the task is that of the call to AsTask, so that errors involving the task
+	// (e.g. conflicting key types, or the task failing) refer to the code which composed the graphs.
 	AsTask(exposeKeys ...ID) (Task, error)
 
+	// Instantiate produces a copy of this Graph whose tasks read and bind keys in the given
+	// namespace, so that the Graph can be used as a template which is embedded (e.g. with AsTask)
+	// multiple times in the same parent graph. Each key ID used by the graph is rewritten to an ID
+	// with the same id in the namespace (nested under the key's own namespace, if any), except for
+	// the IDs in remap, which are mapped to the given IDs (typically the keys of the parent graph
+	// from which the template reads its inputs, or to which it binds its outputs).
+	//
+	// The copy is named after the namespace and this Graph, and has the same options.
+	Instantiate(namespace string, remap map[ID]ID) (Graph, error)
+
 	// Graphviz produces a graphviz representation of the graph, with the tasks as nodes and the
 	// dependencies as edges. This output can be pased into tools like
 	// https://dreampuf.github.io/GraphvizOnline or https://dot-to-ascii.ggerganov.com/ to view the
-	// structure of the graph.
+	// structure of the graph. Virtual keys (see VirtualKey) read by tasks are shown as dashed nodes
+	// between the tasks providing the underlying keys and the tasks reading them.
 	//
 	// The includeInputs parameter controls whether graph inputs are included in the output; including
 	// them tends to make the graph significantly more complicated and harder for the graphviz engine
@@ -90,6 +152,45 @@ type Graph interface {
 type runState struct {
 	Binder
 	signals map[string]chan struct{}
+
+	// checkpointer, if set, is used to save the progress of the run.
+	checkpointer Checkpointer
+	// completed, if set, contains the names of the tasks which completed in a previous run that is
+	// being resumed.
+	completed set.Set[string]
+	// earlyStop, if set, is used to stop the run once the graph's outputs are bound.
+	earlyStop *earlyStop
+}
+
+// taskDone records that the task has completed (or was skipped as it completed previously).
+func (rs *runState) taskDone(t Task) {
+	if rs.earlyStop != nil {
+		rs.earlyStop.taskDone(rs, t)
+	}
+}
+
+func (g *graph) newRunState(binder Binder) *runState {
+	rs := &runState{
+		Binder:  binder,
+		signals: map[string]chan struct{}{},
+	}
+	for _, gn := range g.nodes {
+		rs.signals[gn.id] = make(chan struct{})
+	}
+	return rs
+}
+
+// completedPreviously returns whether the task completed in a previous run which is being resumed.
+// A task which provides keys is only treated as completed if all of them are bound, as its
+// dependents would otherwise wait for them forever.
+func (rs *runState) completedPreviously(t Task) bool {
+	if rs.completed == nil {
+		return false
+	}
+	if len(t.Provides()) > 0 {
+		return rs.Has(t.Provides()...)
+	}
+	return rs.completed.Contains(t.Name())
 }
 
 func (rs *runState) signal(ctx context.Context, childID string) (err error) {
@@ -123,9 +224,13 @@ type graphNode struct {
 	dependentsByKey map[ID][]*graphNode
 	tracer          trace.Tracer
 	logger          Logger
+	strict          *StrictDependencies
+	// index is the position of the node in a topological ordering of the graph's nodes.
+	index int
 }
 
 const (
+	traceTaskgraphPrefix           = "taskgraph."
 	traceTaskgraphAbsentKeysPrefix = "taskgraph.absent_keys."
 )
 
@@ -148,19 +253,55 @@ func (gn *graphNode) execute(ctx context.Context, rs *runState) (err error) {
 	// are available and start executing without receiving from the signal channel.
 	close(rs.signals[gn.id])
 
+	if rs.completedPreviously(gn.task) {
+		gn.logger.Debugf("Skipping task %s, which completed in a previous run", gn.task.Name())
+		rs.taskDone(gn.task)
+		return gn.signalDependents(ctx, rs)
+	}
+
+	ctx = withTask(ctx, gn.task.Name(), 1)
+	ctx = withPlanPosition(ctx, gn.index)
 	tCtx, span := gn.tracer.Start(ctx, gn.task.Name())
 	defer span.End()
+	span.SetAttributes(
+		attribute.String(traceTaskgraphPrefix+"run_id", RunIDFromContext(ctx)),
+		attribute.StringSlice(traceTaskgraphPrefix+"graph_path", graphPath(ctx)),
+		attribute.Int(traceTaskgraphPrefix+"attempt", AttemptFromContext(ctx)),
+	)
+
+	logger := withLogFields(gn.logger, map[string]any{
+		"run_id": RunIDFromContext(ctx),
+		"task":   gn.task.Name(),
+	})
+	logger.Debugf("Starting task %s", gn.task.Name())
+	defer logger.Debugf("Finished task %s", gn.task.Name())
+
+	if planUnmarkedSideEffect(tCtx, gn.task) {
+		rs.taskDone(gn.task)
+		return gn.signalDependents(tCtx, rs)
+	}
 
-	gn.logger.Debugf("Starting task %s", gn.task.Name())
-	defer gn.logger.Debugf("Finished task %s", gn.task.Name())
+	var taskBinder Binder = rs
+	var audit *auditBinder
+	if gn.strict != nil {
+		audit = newAuditBinder(rs, gn.task, *gn.strict, RunIDFromContext(ctx))
+		taskBinder = audit
+	}
 
-	bindings, err := gn.task.Execute(tCtx, rs)
+	bindings, err := gn.task.Execute(tCtx, taskBinder)
 	if err != nil {
 		span.RecordError(err)
-		return wrapStackErrorf("task %s: %w", gn.task.Name(), err)
+		return gn.newTaskError(ctx, err)
+	}
+	if audit != nil {
+		audit.reportUnused()
+	}
+	if err := validateBindings(bindings); err != nil {
+		span.RecordError(err)
+		return gn.newTaskError(ctx, err)
 	}
 	if err := rs.Store(bindings...); err != nil {
-		return wrapStackErrorf("task %s: %w", gn.task.Name(), err)
+		return gn.newTaskError(ctx, err)
 	}
 
 	var missing []string
@@ -193,25 +334,42 @@ func (gn *graphNode) execute(ctx context.Context, rs *runState) (err error) {
 	}
 
 	if len(extra) > 0 || len(missing) > 0 {
-		return wrapStackErrorf(
-			"task %s: mismatch between task Provides declaration and returned bindings: missing bindings [%s], got extra bindings [%s]",
-			gn.task.Name(),
+		return gn.newTaskError(ctx, fmt.Errorf(
+			"mismatch between task Provides declaration and returned bindings: missing bindings [%s], got extra bindings [%s]",
 			strings.Join(missing, ", "),
 			strings.Join(extra, ", "),
-		)
+		))
 	}
 
 	if len(errors) > 0 {
-		gn.logger.Debugf(
+		logger.Debugf(
 			"task %s has binding errors: %s",
 			gn.task.Name(),
 			strings.Join(errors, ", "),
 		)
 	}
 
+	if rs.checkpointer != nil {
+		// Tasks such as those created with AsTask store their bindings directly rather than
+		// returning them, so the bindings are read back from the binder.
+		saved := make([]Binding, 0, len(gn.task.Provides()))
+		for _, id := range gn.task.Provides() {
+			saved = append(saved, rs.Get(id))
+		}
+		if err := rs.checkpointer.Save(tCtx, gn.task.Name(), saved); err != nil {
+			return gn.newTaskError(ctx, err)
+		}
+	}
+
+	rs.taskDone(gn.task)
+	return gn.signalDependents(tCtx, rs)
+}
+
+// signalDependents signals the task's dependents so that they can check if they are ready to run.
+func (gn *graphNode) signalDependents(ctx context.Context, rs *runState) error {
 	for _, dependent := range gn.dependents {
 		gn.logger.Debugf("task %s signalling dependent %s\n", gn.task.Name(), dependent.task.Name())
-		if err := rs.signal(tCtx, dependent.id); err != nil {
+		if err := rs.signal(ctx, dependent.id); err != nil {
 			return err
 		}
 	}
@@ -260,6 +418,26 @@ type graph struct {
 	nodes                        []*graphNode
 	tracer                       trace.Tracer
 	logger                       Logger
+	strict                       *StrictDependencies
+
+	// finally contains the nodes of tasks wrapped in a Finally, which are not in nodes.
+	finally []*graphNode
+
+	// inputDefaults are the bindings used for inputs which are not passed to Run.
+	inputDefaults map[ID]Binding
+	// optionalInputs are the keys which tasks read if they are bound (see optionalDependsOf), and
+	// which are not provided by any task in the graph.
+	optionalInputs set.Set[ID]
+	// outputs are the IDs declared with WithOutputs.
+	outputs   []ID
+	earlyStop bool
+	// noCompensation is set by WithoutCompensation.
+	noCompensation bool
+	// resources are the resource pools added with WithResource.
+	resources map[string]ResourceLimiter
+
+	// shared tracks the runs started with RunShared which are in progress.
+	shared sharedRuns
 }
 
 func (g *graph) buildInputBinder(inputs ...Binding) (Binder, error) {
@@ -268,6 +446,13 @@ func (g *graph) buildInputBinder(inputs ...Binding) (Binder, error) {
 	if err := b.Store(inputs...); err != nil {
 		return nil, wrapStackErrorf("duplicate input: %w", err)
 	}
+	defaults := g.unboundDefaults(b)
+	if err := b.Store(defaults...); err != nil {
+		return nil, err
+	}
+	if err := validateBindings(slices.Concat(inputs, defaults)); err != nil {
+		return nil, wrapStackErrorf("input: %w", err)
+	}
 
 	var missingInputs []string
 	for requiredInput := range g.allDependencies.Difference(g.allProvided).Iter() {
@@ -289,7 +474,31 @@ func (g *graph) Check(inputs ...Binding) error {
 }
 
 // Run is Graph.Run.
-func (g *graph) Run(ctx context.Context, inputs ...Binding) (b Binder, err error) {
+func (g *graph) Run(ctx context.Context, inputs ...Binding) (Binder, error) {
+	return g.run(ctx, runConfig{}, inputs...)
+}
+
+// Resume is Graph.Resume.
+func (g *graph) Resume(
+	ctx context.Context,
+	checkpointer Checkpointer,
+	inputs ...Binding,
+) (Binder, error) {
+	return g.run(ctx, runConfig{checkpointer: checkpointer}, inputs...)
+}
+
+// runConfig configures a single run of the graph.
+type runConfig struct {
+	// checkpointer, if set, is used to save and resume the progress of the run.
+	checkpointer Checkpointer
+	// outputsOnly is set if only the graph's declared outputs are needed, which allows the run to be
+	// stopped early.
+	outputsOnly bool
+}
+
+// run executes the graph with the given configuration.
+func (g *graph) run(ctx context.Context, cfg runConfig, inputs ...Binding) (b Binder, err error) {
+	ctx = withNewRunID(ctx)
 	startTime := time.Now()
 	defer func() {
 		result := "success"
@@ -309,53 +518,135 @@ func (g *graph) Run(ctx context.Context, inputs ...Binding) (b Binder, err error
 		base:    base,
 		overlay: outputs,
 	}
+	rs := g.newRunState(overlay)
+	if cfg.outputsOnly && g.earlyStop {
+		rs.earlyStop = g.newEarlyStop()
+	}
+
+	if checkpointer := cfg.checkpointer; checkpointer != nil {
+		cp, err := checkpointer.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := overlay.Store(cp.Bindings...); err != nil {
+			return nil, wrapStackErrorf("resuming from checkpoint: %w", err)
+		}
+		rs.checkpointer = checkpointer
+		rs.completed = set.NewSet[string](cp.Tasks...)
+	}
+
+	var cl *compensationLog
+	if !g.noCompensation && planRecorderFromContext(ctx) == nil {
+		cl = &compensationLog{}
+	}
+	// If this run is nested in the task of another run, its compensating actions are added to the
+	// log of that run if it succeeds.
+	parentLog := compensationLogFromContext(ctx)
+	// Nested graphs record their compensating actions in the log of this run.
+	ctx = withCompensationLog(ctx, cl)
 
 	tCtx, span := g.tracer.Start(ctx, g.name)
 	defer span.End()
-	if err := g.runWithBinder(tCtx, overlay); err != nil {
+	span.SetAttributes(attribute.String(traceTaskgraphPrefix+"run_id", RunIDFromContext(ctx)))
+	if err := g.runWithState(tCtx, rs); err != nil {
 		span.RecordError(err)
+		if cl != nil {
+			if cErr := g.compensate(tCtx, cl); cErr != nil {
+				return nil, errors.Join(err, cErr)
+			}
+		}
 		return nil, err
 	}
+	if parentLog != nil && cl != nil {
+		parentLog.chain(cl)
+	}
 
 	return outputs, nil
 }
 
-// Sets up the per-run state of the graph, and runs all of the tasks in their own goroutines until
-// all have terminated. If any task returns an error, the entire graph run is cancelled.
-func (g *graph) runWithBinder(ctx context.Context, binder Binder) error {
-	rs := &runState{
-		Binder:  binder,
-		signals: map[string]chan struct{}{},
-	}
-	for _, gn := range g.nodes {
-		rs.signals[gn.id] = make(chan struct{})
-	}
+// Runs all of the tasks in their own goroutines until all have terminated, using the given per-run
+// state. If any task returns an error, the entire graph run is cancelled, and a RunError containing
+// the errors of all of the tasks which failed is returned.
+func (g *graph) runWithState(ctx context.Context, rs *runState) error {
+	ctx = withGraphPath(ctx, g.name)
+	ctx = withResources(ctx, g.resources)
+	// Cancels any tasks which are still running if the run is stopped early.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// errgroup always cancels the derived context before returning from Wait(), so the select below
 	// must listen to the parent context's Done() channel.
 	eg, egCtx := errgroup.WithContext(ctx)
 
+	taskErrs := &taskErrors{}
 	for _, gn := range g.nodes {
-		eg.Go(gn.runFunc(egCtx, rs))
+		run := gn.runFunc(egCtx, rs)
+		eg.Go(func() error {
+			err := run()
+			if err == nil {
+				return nil
+			}
+			te, ok := err.(*TaskError)
+			if !ok {
+				te = gn.newTaskError(ctx, err)
+			}
+			taskErrs.record(egCtx, te)
+			// Returned so that it is the cause of egCtx being cancelled.
+			return te
+		})
 	}
 
-	errCh := make(chan error)
+	// Buffered so that the goroutine can exit if the run is stopped or cancelled before the tasks
+	// finish, and nothing receives from the channel.
+	errCh := make(chan error, 1)
 
 	go func() {
 		errCh <- eg.Wait()
 	}()
 
+	// The errors of the tasks are recorded, so only the error of the context (if any) is needed.
+	var cause error
+	finished, stopped := false, false
 	select {
-	case err := <-errCh:
-		return err
+	case <-errCh:
+		finished = true
+	case <-rs.stopped():
+		stopped = true
 	case <-ctx.Done():
-		return ctx.Err()
+		cause = ctx.Err()
+	}
+	// Finally tasks must wait for the other tasks to finish or be cancelled, as must the compensating
+	// actions taken when the run fails, so that tasks which complete after the run was cancelled are
+	// compensated too.
+	if len(g.finally) > 0 || (!stopped && compensationLogFromContext(ctx) != nil) {
+		cancel()
+		if !finished {
+			<-errCh
+		}
+	}
+	var err error
+	if !stopped {
+		err = taskErrs.runError(g.name, cause)
+	}
+	if len(g.finally) == 0 {
+		return err
+	}
+
+	if finallyErr := g.runFinally(ctx, rs.Binder, err); finallyErr != nil {
+		return errors.Join(err, finallyErr)
 	}
+	return err
 }
 
 func (g *graph) AsTask(exposeKeys ...ID) (Task, error) {
 	depends := g.allDependencies.Difference(g.allProvided).ToSlice()
+	depends = slices.DeleteFunc(depends, func(id ID) bool {
+		_, ok := g.inputDefaults[id]
+		return ok
+	})
+	sortIDs(depends)
 	exposeSet := set.NewSet[ID](exposeKeys...)
+	internalKeys := g.allProvided.Difference(exposeSet)
 	if difference := exposeSet.Difference(g.allProvided); difference.Cardinality() > 0 {
 		var missing []string
 		for id := range difference.Iter() {
@@ -364,14 +655,50 @@ func (g *graph) AsTask(exposeKeys ...ID) (Task, error) {
 		return nil, wrapStackErrorf("%w: %s", ErrExposedKeyNotProvided, strings.Join(missing, ", "))
 	}
 
-	return NewTask(g.name, func(ctx context.Context, external Binder) ([]Binding, error) {
+	dependSet := set.NewSet[ID](depends...)
+	var dependDescs, provideDescs []KeyDescriptor
+	for _, t := range g.tasks {
+		for _, d := range dependencyDescriptors(t) {
+			if dependSet.Contains(d.ID) && d.Type != nil {
+				dependDescs = append(dependDescs, d)
+			}
+		}
+		for _, d := range provisionDescriptors(t) {
+			if exposeSet.Contains(d.ID) && d.Type != nil {
+				provideDescs = append(provideDescs, d)
+			}
+		}
+	}
+
+	optionalDepends := g.optionalInputs.Clone()
+	for id := range g.inputDefaults {
+		optionalDepends.Add(id)
+	}
+	t := &task{
+		name:            g.name,
+		depends:         depends,
+		provides:        exposeKeys,
+		location:        getLocation(2),
+		dependDescs:     dependDescs,
+		provideDescs:    provideDescs,
+		optionalDepends: optionalDepends.ToSlice(),
+	}
+	sortIDs(t.optionalDepends)
+	t.fn = func(ctx context.Context, external Binder) ([]Binding, error) {
 		gtb := &graphTaskBinder{
-			internal:   NewBinder(),
-			external:   external,
-			exposeKeys: exposeSet,
+			internal:     NewBinder(),
+			external:     external,
+			exposeKeys:   exposeSet,
+			internalKeys: internalKeys,
+		}
+		if err := gtb.internal.Store(g.unboundDefaults(external)...); err != nil {
+			return nil, err
 		}
 
-		if err := g.runWithBinder(ctx, gtb); err != nil {
+		if g.noCompensation {
+			ctx = withCompensationLog(ctx, nil)
+		}
+		if err := g.runWithState(ctx, g.newRunState(gtb)); err != nil {
 			return nil, err
 		}
 
@@ -395,30 +722,107 @@ func (g *graph) AsTask(exposeKeys ...ID) (Task, error) {
 		// The exposed keys are added to the external binder via the graphTaskBinder, so we don't return
 		// any bindings here (as to do so would cause a duplicate binding error).
 		return nil, nil
-	}, depends, exposeKeys), nil
+	}
+	return t, nil
+}
+
+// graphvizVirtualKeys describes how a task reads keys through virtual keys, for Graphviz.
+type graphvizVirtualKeys struct {
+	// direct contains the IDs which the task reads directly.
+	direct set.Set[ID]
+	// nodeIDs maps the location of each virtual key read by the task to the ID of its graphviz node,
+	// and byKey maps each ID read through virtual keys to the nodes of those virtual keys.
+	nodeIDs map[string]string
+	byKey   map[ID][]string
+}
+
+func newGraphvizVirtualKeys(n *graphNode) *graphvizVirtualKeys {
+	vk := &graphvizVirtualKeys{
+		direct:  set.NewSet[ID](),
+		nodeIDs: map[string]string{},
+		byKey:   map[ID][]string{},
+	}
+	var locations []string
+	byLocation := map[string][]ID{}
+	for _, d := range dependencyDescriptors(n.task) {
+		if d.Virtual == "" {
+			vk.direct.Add(d.ID)
+			continue
+		}
+		if _, ok := byLocation[d.Virtual]; !ok {
+			locations = append(locations, d.Virtual)
+		}
+		byLocation[d.Virtual] = append(byLocation[d.Virtual], d.ID)
+	}
+	sort.Strings(locations)
+	for i, location := range locations {
+		nodeID := fmt.Sprintf("%s_virtual_%d", n.id, i)
+		vk.nodeIDs[location] = nodeID
+		for _, id := range byLocation[location] {
+			vk.byKey[id] = append(vk.byKey[id], nodeID)
+		}
+	}
+	return vk
+}
+
+// targets returns the IDs of the graphviz nodes which should have an edge for the given key: the
+// task's node (if it reads the key directly) and the nodes of any virtual keys reading the key.
+func (vk *graphvizVirtualKeys) targets(n *graphNode, id ID) []string {
+	targets := vk.byKey[id]
+	if vk.direct.Contains(id) || len(targets) == 0 {
+		targets = append([]string{n.id}, targets...)
+	}
+	return targets
 }
 
 func (g *graph) Graphviz(includeInputs bool) string {
 	var nodes []string
 	var edges []string
 
+	virtualKeys := map[*graphNode]*graphvizVirtualKeys{}
+	for _, n := range g.nodes {
+		vk := newGraphvizVirtualKeys(n)
+		virtualKeys[n] = vk
+		for location, nodeID := range vk.nodeIDs {
+			nodes = append(
+				nodes,
+				fmt.Sprintf(
+					"  %s [label=\"Virtual - %s\", shape=box, style=dashed];",
+					nodeID,
+					filepath.Base(location),
+				),
+			)
+			edges = append(edges, fmt.Sprintf("  %s -> %s;", nodeID, n.id))
+		}
+	}
+
 	for _, n := range g.nodes {
 		nodes = append(nodes, fmt.Sprintf("  %s [label=\"%s\"];", n.id, n.task.Name()))
 		if includeInputs {
 			for _, dep := range n.task.Depends() {
 				if !g.allProvided.Contains(dep) {
 					inputID := fmt.Sprintf("%s_input_%s", n.id, dep.id)
+					label := fmt.Sprintf("Input - %s", dep)
+					if d, ok := g.inputDefaults[dep]; ok && d.Status() == Absent {
+						label += " (optional)"
+					} else if ok {
+						label += " (default)"
+					}
 					nodes = append(
 						nodes,
-						fmt.Sprintf("  %s [label=\"Input - %s\", shape=diamond];", inputID, dep),
+						fmt.Sprintf("  %s [label=\"%s\", shape=diamond];", inputID, label),
 					)
-					edges = append(edges, fmt.Sprintf("  %s -> %s;", inputID, n.id))
+					for _, target := range virtualKeys[n].targets(n, dep) {
+						edges = append(edges, fmt.Sprintf("  %s -> %s;", inputID, target))
+					}
 				}
 			}
 		}
 		for k, deps := range n.dependentsByKey {
 			for _, dep := range deps {
-				edges = append(edges, fmt.Sprintf("  %s -> %s [label=\"%s\"];", n.id, dep.id, k))
+				for _, target := range virtualKeys[dep].targets(dep, k) {
+					edges = append(edges, fmt.Sprintf("  %s -> %s [label=\"%s\"];", n.id, target, k))
+				}
 			}
 		}
 		for _, dep := range n.task.Provides() {
@@ -436,6 +840,41 @@ func (g *graph) Graphviz(includeInputs bool) string {
 		}
 	}
 
+	// Finally tasks run after all the other tasks, so are drawn (with their edges) dashed.
+	providers := map[ID]*graphNode{}
+	for _, n := range g.nodes {
+		for _, id := range n.task.Provides() {
+			providers[id] = n
+		}
+	}
+	for _, n := range g.finally {
+		nodes = append(
+			nodes,
+			fmt.Sprintf("  %s [label=\"Finally - %s\", style=dashed];", n.id, n.task.Name()),
+		)
+		for _, dep := range n.task.Depends() {
+			if dep == RunResultKey.ID() {
+				continue
+			}
+			if provider, ok := providers[dep]; ok {
+				edges = append(
+					edges,
+					fmt.Sprintf("  %s -> %s [label=\"%s\", style=dashed];", provider.id, n.id, dep),
+				)
+			} else if includeInputs {
+				inputID := fmt.Sprintf("%s_input_%s", n.id, dep.id)
+				nodes = append(
+					nodes,
+					fmt.Sprintf("  %s [label=\"Input - %s\", shape=diamond];", inputID, dep),
+				)
+				edges = append(edges, fmt.Sprintf("  %s -> %s [style=dashed];", inputID, n.id))
+			}
+		}
+	}
+
+	// Tasks may read the same key more than once (e.g. directly and through a virtual key).
+	nodes = set.NewSet(nodes...).ToSlice()
+	edges = set.NewSet(edges...).ToSlice()
 	sort.Strings(nodes)
 	sort.Strings(edges)
 
@@ -454,9 +893,15 @@ type Logger interface {
 }
 
 type graphOptions struct {
-	tasks  []Task
-	tracer trace.Tracer
-	logger Logger
+	tasks          []Task
+	tracer         trace.Tracer
+	logger         Logger
+	strict         *StrictDependencies
+	inputDefaults  []Binding
+	outputs        []ID
+	earlyStop      bool
+	noCompensation bool
+	resources      map[string]ResourceLimiter
 }
 
 // A GraphOption is used to configure a new Graph.
@@ -485,6 +930,10 @@ func WithTracer(tracer trace.Tracer) GraphOption {
 }
 
 // WithLogger sets a logger for the graph.
+//
+// The messages logged for each task have "run_id" and "task" fields (see RunIDFromContext) only if
+// the logger supports fields in the way logrus loggers do, i.e. it has a
+// WithFields(logrus.Fields) *logrus.Entry method; other loggers receive the messages without them.
 func WithLogger(logger Logger) GraphOption {
 	return func(opts *graphOptions) error {
 		opts.logger = logger
@@ -510,6 +959,15 @@ func New(name string, opts ...GraphOption) (Graph, error) {
 	if o.logger == nil {
 		o.logger = log
 	}
+	if o.strict != nil && o.strict.Report == nil {
+		// Copy the options to avoid modifying those captured by the GraphOption.
+		strict := *o.strict
+		logger := o.logger
+		strict.Report = func(v DependencyViolation) {
+			logger.Debugf("dependency violation in graph %s: %s", name, v)
+		}
+		o.strict = &strict
+	}
 
 	g := &graph{
 		name:            name,
@@ -518,6 +976,7 @@ func New(name string, opts ...GraphOption) (Graph, error) {
 		allProvided:     set.NewSet[ID](),
 		tracer:          o.tracer,
 		logger:          o.logger,
+		strict:          o.strict,
 	}
 
 	provideTasks := map[string][]string{}
@@ -532,17 +991,39 @@ func New(name string, opts ...GraphOption) (Graph, error) {
 				fmt.Errorf("tasks must have a name and location: (%s, %s)", t.Name(), t.Location()),
 			)
 		}
+		if ct, ok := t.(*task); ok && ct.check != nil {
+			if err := ct.check(); err != nil {
+				badTaskErrs = errors.Join(badTaskErrs, wrapStackErrorf("task %s: %w", t.Name(), err))
+			}
+		}
 		node := &graphNode{
 			id:              sanitizeTaskName(t.Name()),
 			task:            t,
 			dependentsByKey: map[ID][]*graphNode{},
 			tracer:          g.tracer,
 			logger:          g.logger,
+			strict:          o.strict,
 		}
-		g.nodes = append(g.nodes, node)
-
 		taskLocations[t.Name()] = append(taskLocations[t.Name()], t.Location())
 
+		if finallyOptionsOf(t) != nil {
+			if len(t.Provides()) > 0 {
+				badTaskErrs = errors.Join(
+					badTaskErrs,
+					wrapStackErrorf("task %s: %w", t.Name(), ErrFinallyProvidesKeys),
+				)
+			}
+			// Finally tasks have no dependents, and RunResultKey is bound when they are run.
+			for _, dep := range t.Depends() {
+				if dep != RunResultKey.ID() {
+					g.allDependencies.Add(dep)
+				}
+			}
+			g.finally = append(g.finally, node)
+			continue
+		}
+		g.nodes = append(g.nodes, node)
+
 		g.allDependencies.Append(t.Depends()...)
 		for _, dep := range t.Depends() {
 			nodesByDep[dep] = append(nodesByDep[dep], node)
@@ -592,6 +1073,24 @@ func New(name string, opts ...GraphOption) (Graph, error) {
 		)
 	}
 
+	if err := checkKeyTypes(g.tasks); err != nil {
+		return nil, err
+	}
+	g.addOptionalDependencies(nodesByDep)
+
+	defaults, err := g.newInputDefaults(o.inputDefaults)
+	if err != nil {
+		return nil, err
+	}
+	g.inputDefaults = defaults
+
+	g.outputs, g.earlyStop = o.outputs, o.earlyStop
+	g.noCompensation = o.noCompensation
+	g.resources = o.resources
+	if err := g.checkOutputs(); err != nil {
+		return nil, err
+	}
+
 	for _, node := range g.nodes {
 		seen := map[string]bool{}
 		for _, p := range node.task.Provides() {
@@ -620,10 +1119,93 @@ func New(name string, opts ...GraphOption) (Graph, error) {
 			return nil, err
 		}
 	}
+	setTopologicalIndexes(g.nodes)
+	// Finally tasks run after all of the other tasks.
+	for i, node := range g.finally {
+		node.index = len(g.nodes) + i
+	}
 
 	return g, nil
 }
 
+// setTopologicalIndexes sets the index of each node, such that every node has a greater index than
+// the nodes it depends on. Independent nodes are ordered as they are in nodes. The graph must not
+// have cycles.
+func setTopologicalIndexes(nodes []*graphNode) {
+	dependencies := map[*graphNode]int{}
+	for _, node := range nodes {
+		for _, dependent := range node.dependents {
+			dependencies[dependent]++
+		}
+	}
+	indexed := map[*graphNode]bool{}
+	for i := range nodes {
+		// Picks the first node whose dependencies have all been indexed; this is O(n^2), which is
+		// fine compared to checking for cycles.
+		for _, node := range nodes {
+			if indexed[node] || dependencies[node] > 0 {
+				continue
+			}
+			node.index = i
+			indexed[node] = true
+			for _, dependent := range node.dependents {
+				dependencies[dependent]--
+			}
+			break
+		}
+	}
+}
+
+// checkKeyTypes asserts that every typed key used by the given tasks has a consistent type for its
+// ID, returning ErrKeyTypeConflict listing the conflicting keys otherwise.
+func checkKeyTypes(tasks []Task) error {
+	type usage struct {
+		desc KeyDescriptor
+		task Task
+	}
+	usages := map[ID][]usage{}
+	for _, t := range tasks {
+		descs := append(dependencyDescriptors(t), provisionDescriptors(t)...)
+		for _, d := range descs {
+			if d.Type != nil {
+				usages[d.ID] = append(usages[d.ID], usage{d, t})
+			}
+		}
+	}
+
+	var conflicts []string
+	for id, us := range usages {
+		types := set.NewSet[reflect.Type]()
+		for _, u := range us {
+			types.Add(u.desc.Type)
+		}
+		if types.Cardinality() < 2 {
+			continue
+		}
+		seen := set.NewSet[string]()
+		var keys []string
+		for _, u := range us {
+			desc := fmt.Sprintf(
+				"%s at %s (used by task %s - %s)",
+				u.desc.Type,
+				u.desc.Location,
+				u.task.Name(),
+				u.task.Location(),
+			)
+			if seen.Add(desc) {
+				keys = append(keys, desc)
+			}
+		}
+		sort.Strings(keys)
+		conflicts = append(conflicts, fmt.Sprintf("%s (%s)", id, strings.Join(keys, ", ")))
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return wrapStackErrorf("%w: %s", ErrKeyTypeConflict, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
 var sanitizeRegex = regexp.MustCompile("[^a-zA-Z0-9]+")
 
 func sanitizeTaskName(name string) string {
@@ -3,6 +3,9 @@ package taskgraph_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -182,6 +185,43 @@ func TestGraphErrors(t *testing.T) {
 		}
 	})
 
+	t.Run("ErrKeyTypeConflict", func(t *testing.T) {
+		intKey1 := tg.NewKey[int]("key1")
+		if _, err := tg.New("test_graph", tg.WithTasks(
+			tg.SimpleTask1[string, string](
+				"task1",
+				key2,
+				func(_ context.Context, arg string) (string, error) { return arg, nil },
+				key1,
+			),
+			tg.SimpleTask[int]("task2", intKey1, func(_ context.Context, _ tg.Binder) (int, error) {
+				return 1, nil
+			}),
+		)); !errors.Is(err, tg.ErrKeyTypeConflict) {
+			t.Errorf("expected error %v; got %v", tg.ErrKeyTypeConflict, err)
+		}
+	})
+
+	t.Run("ErrKeyTypeConflict across AsTask", func(t *testing.T) {
+		intKey1 := tg.NewKey[int]("key1")
+		inner := tgt.Must[tg.Graph](t)(tg.New("inner", tg.WithTasks(
+			tg.SimpleTask[int]("task1", intKey1, func(_ context.Context, _ tg.Binder) (int, error) {
+				return 1, nil
+			}),
+		)))
+		if _, err := tg.New("test_graph", tg.WithTasks(
+			tgt.Must[tg.Task](t)(inner.AsTask(intKey1.ID())),
+			tg.SimpleTask1[bool, string](
+				"task2",
+				key2,
+				func(_ context.Context, _ bool) (string, error) { return "", nil },
+				tg.Presence(key1),
+			),
+		)); !errors.Is(err, tg.ErrKeyTypeConflict) {
+			t.Errorf("expected error %v; got %v", tg.ErrKeyTypeConflict, err)
+		}
+	})
+
 	t.Run("ErrExposedKeyNotProvided", func(t *testing.T) {
 		if _, err := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(
 			tg.NewTask("task", tgt.DummyTaskFunc(), nil, nil),
@@ -190,3 +230,53 @@ func TestGraphErrors(t *testing.T) {
 		}
 	})
 }
+
+const wantVirtualGraphviz = `digraph G {
+  A [label="A"];
+  B [label="B"];
+  C [label="C"];
+  C_output_c [label="Output", shape=diamond];
+  C_virtual_0 [label="Virtual - graph_test.go:LINE", shape=box, style=dashed];
+
+  A -> B [label="a"];
+  A -> C_virtual_0 [label="a"];
+  B -> C [label="b"];
+  B -> C_virtual_0 [label="b"];
+  C -> C_output_c [label="c"];
+  C_virtual_0 -> C;
+}
+`
+
+func TestGraphVirtualKeys(t *testing.T) {
+	keyA := tg.NewKey[int]("a")
+	keyB := tg.NewKey[int]("b")
+	keyC := tg.NewKey[int]("c")
+	_, _, line, _ := runtime.Caller(0)
+	sum := tg.Combine2(keyA, keyB, func(a, b int) int { return a + b })
+
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(
+		tg.SimpleTask[int]("A", keyA, func(_ context.Context, _ tg.Binder) (int, error) {
+			return 1, nil
+		}),
+		tg.SimpleTask1[int, int]("B", keyB, func(_ context.Context, a int) (int, error) {
+			return a * 2, nil
+		}, keyA),
+		tg.SimpleTask2[int, int]("C", keyC, func(_ context.Context, s, b int) (int, error) {
+			return s * b, nil
+		}, sum, keyB),
+	)))
+
+	want := strings.ReplaceAll(wantVirtualGraphviz, "LINE", fmt.Sprint(line+1))
+	if diff := cmp.Diff(want, g.Graphviz(false)); diff != "" {
+		t.Errorf("Unexpected diff in Graphviz output:\n%s", diff)
+	}
+
+	tgt.Test{
+		Graph: g,
+		WantBindings: []tgt.BindingMatcher{
+			tgt.Match(keyC.Bind(6)),
+			tgt.MatchKey(sum, 3),
+		},
+		CheckExcessBindings: true,
+	}.Run(t)
+}
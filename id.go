@@ -1,6 +1,9 @@
 package taskgraph
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 // ID represents a type-parameter-less identifier for a Key.
 type ID struct {
@@ -19,3 +22,10 @@ func (i ID) String() string {
 	// Double underscore is unconventional, but it is safe for graphviz IDs.
 	return fmt.Sprintf("%s__%s", i.namespace, i.id)
 }
+
+// sortIDs sorts IDs by their string representation.
+func sortIDs(ids []ID) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+}
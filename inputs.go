@@ -0,0 +1,114 @@
+package taskgraph
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+
+	set "github.com/deckarep/golang-set/v2"
+)
+
+// ErrDefaultNotAnInput is returned by New if a default is declared (with WithInputDefault or
+// WithOptionalInput) for a key which is not an input of the graph, i.e. a key which is not a
+// dependency of any task, or which is provided by a task.
+var ErrDefaultNotAnInput = errors.New("default(s) declared for key(s) which are not graph inputs")
+
+// WithInputDefault declares default values for inputs of the graph. If an input binding for one of
+// the keys is not passed to Graph.Run (or Graph.Check), the default binding is used instead. When
+// the graph is run as a task (see Graph.AsTask), the keys with defaults are not dependencies of the
+// task unless they are provided by another task in the graph containing it (in which case the task
+// runs after that task), and the defaults are used for any keys which are not bound when the task
+// starts.
+func WithInputDefault(bindings ...Binding) GraphOption {
+	return func(opts *graphOptions) error {
+		opts.inputDefaults = append(opts.inputDefaults, bindings...)
+
+		return nil
+	}
+}
+
+// WithOptionalInput declares optional inputs of the graph, which are bound as absent if they are
+// not passed to Graph.Run. This is equivalent to WithInputDefault with absent bindings.
+func WithOptionalInput(ids ...ID) GraphOption {
+	return func(opts *graphOptions) error {
+		for _, id := range ids {
+			opts.inputDefaults = append(opts.inputDefaults, bindAbsent(id))
+		}
+
+		return nil
+	}
+}
+
+// newInputDefaults checks that the given default bindings are for inputs of the graph, and returns
+// them by ID.
+func (g *graph) newInputDefaults(bindings []Binding) (map[ID]Binding, error) {
+	inputs := g.allDependencies.Difference(g.allProvided)
+	defaults := map[ID]Binding{}
+	var notInputs []string
+	for _, b := range bindings {
+		if _, ok := defaults[b.ID()]; ok {
+			return nil, wrapStackErrorf("%w: default for %q", ErrDuplicateBinding, b.ID())
+		}
+		defaults[b.ID()] = b
+		if !inputs.Contains(b.ID()) {
+			notInputs = append(notInputs, b.ID().String())
+		}
+	}
+	if len(notInputs) > 0 {
+		return nil, wrapStackErrorf("%w: %s", ErrDefaultNotAnInput, strings.Join(notInputs, ", "))
+	}
+	return defaults, nil
+}
+
+// unboundDefaults returns the default bindings for the inputs which are not bound in b, sorted by
+// ID.
+func (g *graph) unboundDefaults(b Binder) []Binding {
+	var res []Binding
+	for id, binding := range g.inputDefaults {
+		if !b.Has(id) {
+			res = append(res, binding)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID().String() < res[j].ID().String()
+	})
+	return res
+}
+
+// optionalDependsOf returns the keys which the task reads if they are bound when it starts.
+func optionalDependsOf(t Task) []ID {
+	if tt, ok := t.(*task); ok {
+		return tt.optionalDepends
+	}
+	return nil
+}
+
+// addOptionalDependencies makes the graph's tasks depend on the keys which they read if bound, if
+// those keys are provided by other tasks in the graph, so that the tasks run after the tasks
+// providing them. The remaining keys are recorded as optional inputs of the graph.
+func (g *graph) addOptionalDependencies(nodesByDep map[ID][]*graphNode) {
+	g.optionalInputs = set.NewSet[ID]()
+	for _, node := range g.nodes {
+		var provided []ID
+		for _, id := range optionalDependsOf(node.task) {
+			switch {
+			case slices.Contains(node.task.Provides(), id):
+			case g.allProvided.Contains(id):
+				provided = append(provided, id)
+			default:
+				g.optionalInputs.Add(id)
+			}
+		}
+		if len(provided) == 0 {
+			continue
+		}
+		t := copyTask(node.task)
+		t.depends = append(append([]ID{}, t.depends...), provided...)
+		node.task = t
+		g.allDependencies.Append(provided...)
+		for _, id := range provided {
+			nodesByDep[id] = append(nodesByDep[id], node)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestInputDefaults(t *testing.T) {
+	name := tg.NewKey[string]("name")
+	replicas := tg.NewKey[int]("replicas")
+	region := tg.NewKey[string]("region")
+	result := tg.NewKey[string]("result")
+
+	describe := tg.Reflect[string]{
+		Name:      "describe",
+		ResultKey: result,
+		Fn: func(n string, r int, reg tg.Maybe[string]) string {
+			regVal, err := reg.Get()
+			if err != nil {
+				regVal = "none"
+			}
+			return fmt.Sprintf("%s/%d/%s", n, r, regVal)
+		},
+		Depends: []any{name, replicas, tg.Optional(region)},
+	}.Locate()
+	g := tgt.Must[tg.Graph](t)(tg.New(
+		"test_graph",
+		tg.WithTasks(describe),
+		tg.WithInputDefault(replicas.Bind(3)),
+		tg.WithOptionalInput(region.ID()),
+	))
+
+	tgt.Suite{
+		Graph: g,
+		Tests: []tgt.Test{
+			{
+				Description: "defaults",
+				Inputs:      []tg.Binding{name.Bind("a")},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(result.Bind("a/3/none")),
+				},
+			},
+			{
+				Description: "inputs override defaults",
+				Inputs:      []tg.Binding{name.Bind("a"), replicas.Bind(5), region.Bind("eu")},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(result.Bind("a/5/eu")),
+				},
+			},
+			{
+				Description: "missing input without default",
+				WantError:   tg.ErrMissingInputs,
+			},
+		},
+	}.Run(t)
+
+	t.Run("Check", func(t *testing.T) {
+		if err := g.Check(name.Bind("a")); err != nil {
+			t.Errorf("expected inputs with defaults to be optional; got %v", err)
+		}
+		if err := g.Check(); !errors.Is(err, tg.ErrMissingInputs) {
+			t.Errorf("got %v; want %v", err, tg.ErrMissingInputs)
+		}
+	})
+
+	t.Run("Graphviz", func(t *testing.T) {
+		graphviz := g.Graphviz(true)
+		for _, want := range []string{
+			`label="Input - name"`,
+			`label="Input - replicas (default)"`,
+			`label="Input - region (optional)"`,
+		} {
+			if !strings.Contains(graphviz, want) {
+				t.Errorf("expected graphviz to contain %s; got:\n%s", want, graphviz)
+			}
+		}
+	})
+
+	t.Run("AsTask", func(t *testing.T) {
+		task := tgt.Must[tg.Task](t)(g.AsTask(result.ID()))
+		if got := task.Depends(); len(got) != 1 || got[0] != name.ID() {
+			t.Errorf("got depends %v; want [%s]", got, name.ID())
+		}
+
+		tgt.Test{
+			Task:   task,
+			Inputs: []tg.Binding{name.Bind("a"), region.Bind("us")},
+			WantBindings: []tgt.BindingMatcher{
+				tgt.Match(result.Bind("a/3/us")),
+			},
+		}.Run(t)
+
+		// The task may read its optional inputs, so the inputs are not replaced by the defaults.
+		tgt.Test{
+			Task:   task,
+			Inputs: []tg.Binding{name.Bind("a"), replicas.Bind(5)},
+			WantBindings: []tgt.BindingMatcher{
+				tgt.Match(result.Bind("a/5/none")),
+			},
+			StrictDependencies: true,
+		}.Run(t)
+	})
+}
+
+func TestInputDefaultsProvidedBySibling(t *testing.T) {
+	name := tg.NewKey[string]("name")
+	replicas := tg.NewKey[int]("replicas")
+	result := tg.NewKey[string]("result")
+
+	inner := tgt.Must[tg.Graph](t)(tg.New(
+		"inner",
+		tg.WithTasks(tg.SimpleTask2[string, int, string](
+			"describe",
+			result,
+			func(_ context.Context, n string, r int) (string, error) {
+				return fmt.Sprintf("%s/%d", n, r), nil
+			},
+			name,
+			replicas,
+		)),
+		tg.WithInputDefault(replicas.Bind(3)),
+	))
+	innerTask := tgt.Must[tg.Task](t)(inner.AsTask(result.ID()))
+
+	tgt.Test{
+		Graph: tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(
+			innerTask,
+			tg.SimpleTask[int]("replicas", replicas, func(context.Context, tg.Binder) (int, error) {
+				// Without a dependency on this task, the nested graph would use the default.
+				time.Sleep(10 * time.Millisecond)
+				return 7, nil
+			}),
+		))),
+		Inputs: []tg.Binding{name.Bind("a")},
+		WantBindings: []tgt.BindingMatcher{
+			tgt.Match(result.Bind("a/7")),
+		},
+	}.Run(t)
+}
+
+func TestInputDefaultNotAnInput(t *testing.T) {
+	result := tg.NewKey[string]("result")
+
+	_, err := tg.New(
+		"test_graph",
+		tg.WithTasks(tg.SimpleTask("result", result, func(context.Context, tg.Binder) (string, error) {
+			return "", nil
+		})),
+		tg.WithInputDefault(result.Bind("x")),
+	)
+	if !errors.Is(err, tg.ErrDefaultNotAnInput) {
+		t.Errorf("got %v; want %v", err, tg.ErrDefaultNotAnInput)
+	}
+}
@@ -0,0 +1,162 @@
+package taskgraph
+
+import (
+	"context"
+	"strings"
+)
+
+// Instantiate is Graph.Instantiate.
+func (g *graph) Instantiate(namespace string, remap map[ID]ID) (Graph, error) {
+	var unused []string
+	used := g.allDependencies.Union(g.allProvided).Union(g.optionalInputs)
+	for id := range remap {
+		if !used.Contains(id) {
+			unused = append(unused, id.String())
+		}
+	}
+	if len(unused) > 0 {
+		return nil, wrapStackErrorf("%w: %s", ErrRemappedKeyNotUsed, strings.Join(unused, ", "))
+	}
+
+	km := &keyMapping{
+		toInstance:   map[ID]ID{},
+		fromInstance: map[ID]ID{},
+	}
+	for id := range used.Iter() {
+		instanceID, ok := remap[id]
+		if !ok {
+			ns := namespace
+			if id.namespace != "" {
+				ns = namespace + "." + id.namespace
+			}
+			instanceID = newID(ns, id.id)
+		}
+		km.toInstance[id] = instanceID
+		km.fromInstance[instanceID] = id
+	}
+
+	var tasks []TaskSet
+	for _, t := range g.tasks {
+		tasks = append(tasks, km.wrap(t))
+	}
+	opts := []GraphOption{WithTasks(tasks...), WithTracer(g.tracer), WithLogger(g.logger)}
+	if g.strict != nil {
+		opts = append(opts, WithStrictDependencies(*g.strict))
+	}
+	opts = append(opts, WithOutputs(km.instanceIDs(g.outputs)...))
+	if g.earlyStop {
+		opts = append(opts, WithEarlyStop())
+	}
+	if g.noCompensation {
+		opts = append(opts, WithoutCompensation())
+	}
+	for name, limiter := range g.resources {
+		opts = append(opts, WithResource(name, limiter))
+	}
+	for id, b := range g.inputDefaults {
+		opts = append(opts, WithInputDefault(rebind(b, km.instanceID(id))))
+	}
+	return New(namespace+"/"+g.name, opts...)
+}
+
+// keyMapping maps the key IDs used by a template graph to those used by an instance of it.
+type keyMapping struct {
+	toInstance, fromInstance map[ID]ID
+}
+
+func (km *keyMapping) instanceID(id ID) ID {
+	if instanceID, ok := km.toInstance[id]; ok {
+		return instanceID
+	}
+	return id
+}
+
+func (km *keyMapping) instanceIDs(ids []ID) []ID {
+	res := make([]ID, len(ids))
+	for i, id := range ids {
+		res[i] = km.instanceID(id)
+	}
+	return res
+}
+
+func (km *keyMapping) instanceDescs(descs []KeyDescriptor) []KeyDescriptor {
+	res := make([]KeyDescriptor, len(descs))
+	for i, d := range descs {
+		d.ID = km.instanceID(d.ID)
+		res[i] = d
+	}
+	return res
+}
+
+// wrap returns a copy of the template task which reads and binds the instance's keys.
+func (km *keyMapping) wrap(t Task) Task {
+	it := copyTask(t)
+	it.depends = km.instanceIDs(t.Depends())
+	it.provides = km.instanceIDs(t.Provides())
+	it.optionalDepends = km.instanceIDs(optionalDependsOf(t))
+	it.dependDescs = km.instanceDescs(dependencyDescriptors(t))
+	it.provideDescs = km.instanceDescs(provisionDescriptors(t))
+	it.fn = func(ctx context.Context, b Binder) ([]Binding, error) {
+		bindings, err := t.Execute(ctx, &remapBinder{base: b, km: km})
+		if err != nil {
+			return nil, err
+		}
+		res := make([]Binding, len(bindings))
+		for i, binding := range bindings {
+			res[i] = rebind(binding, km.instanceID(binding.ID()))
+		}
+		return res, nil
+	}
+	return it
+}
+
+// rebind returns a copy of the binding for a different ID.
+func rebind(b Binding, id ID) Binding {
+	if b.ID() == id {
+		return b
+	}
+	return &binding{
+		id:     id,
+		status: b.Status(),
+		value:  b.Value(),
+		err:    b.Error(),
+		info:   keyInfoOf(b),
+	}
+}
+
+// remapBinder implements Binder to allow the tasks of a template graph to use the keys of an
+// instance of the graph. Bindings are stored in, and retrieved from, the base binder using the
+// instance's IDs, but retrieved bindings have the template's IDs.
+type remapBinder struct {
+	base Binder
+	km   *keyMapping
+}
+
+func (rb *remapBinder) Store(bindings ...Binding) error {
+	for _, b := range bindings {
+		if err := rb.base.Store(rebind(b, rb.km.instanceID(b.ID()))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rb *remapBinder) Has(ids ...ID) bool {
+	return rb.base.Has(rb.km.instanceIDs(ids)...)
+}
+
+func (rb *remapBinder) Get(id ID) Binding {
+	return rebind(rb.base.Get(rb.km.instanceID(id)), id)
+}
+
+func (rb *remapBinder) GetAll() []Binding {
+	var res []Binding
+	for _, b := range rb.base.GetAll() {
+		if id, ok := rb.km.fromInstance[b.ID()]; ok {
+			res = append(res, rebind(b, id))
+		} else {
+			res = append(res, b)
+		}
+	}
+	return res
+}
@@ -0,0 +1,76 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestInstantiate(t *testing.T) {
+	templateIn := tg.NewKey[int]("template_in")
+	templateMid := tg.NewKey[int]("template_mid")
+	templateOut := tg.NewKey[int]("template_out")
+	template := tgt.Must[tg.Graph](t)(tg.New("template", tg.WithTasks(
+		tg.SimpleTask1[int, int]("double", templateMid, func(_ context.Context, i int) (int, error) {
+			return i * 2, nil
+		}, templateIn),
+		tg.SimpleTask1[int, int]("increment", templateOut, func(_ context.Context, i int) (int, error) {
+			return i + 1, nil
+		}, templateMid),
+	)))
+
+	primaryIn := tg.NewKey[int]("primary_in")
+	primaryOut := tg.NewKey[int]("primary_out")
+	replicaOut := tg.NewKey[int]("replica_out")
+	// The replica reads its input from the namespaced copy of the template's input key.
+	replicaIn := tg.NewNamespacedKey[int]("replica", "template_in")
+
+	primary := tgt.Must[tg.Graph](t)(template.Instantiate("primary", map[tg.ID]tg.ID{
+		templateIn.ID():  primaryIn.ID(),
+		templateOut.ID(): primaryOut.ID(),
+	}))
+	replica := tgt.Must[tg.Graph](t)(template.Instantiate("replica", map[tg.ID]tg.ID{
+		templateOut.ID(): replicaOut.ID(),
+	}))
+
+	g := tgt.Must[tg.Graph](t)(tg.New("parent", tg.WithTasks(
+		tgt.Must[tg.Task](t)(primary.AsTask(primaryOut.ID())),
+		tgt.Must[tg.Task](t)(replica.AsTask(replicaOut.ID())),
+	)))
+
+	tgt.Test{
+		Graph:  g,
+		Inputs: []tg.Binding{primaryIn.Bind(1), replicaIn.Bind(10)},
+		WantBindings: []tgt.BindingMatcher{
+			tgt.Match(primaryOut.Bind(3)),
+			tgt.Match(replicaOut.Bind(21)),
+		},
+		CheckExcessBindings: true,
+	}.Run(t)
+
+	// The template itself is unchanged.
+	res, err := template.Run(context.Background(), templateIn.Bind(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt.ExpectPresent(t, res, templateOut, 5)
+}
+
+func TestInstantiateUnusedKey(t *testing.T) {
+	in := tg.NewKey[int]("in")
+	out := tg.NewKey[int]("out")
+	unused := tg.NewKey[int]("unused")
+	template := tgt.Must[tg.Graph](t)(tg.New("template", tg.WithTasks(
+		tg.SimpleTask1[int, int]("identity", out, func(_ context.Context, i int) (int, error) {
+			return i, nil
+		}, in),
+	)))
+	if _, err := template.Instantiate("ns", map[tg.ID]tg.ID{
+		unused.ID(): in.ID(),
+	}); !errors.Is(err, tg.ErrRemappedKeyNotUsed) {
+		t.Errorf("expected error %v; got %v", tg.ErrRemappedKeyNotUsed, err)
+	}
+}
@@ -2,6 +2,8 @@ package taskgraph
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
 )
 
 var (
@@ -14,7 +16,8 @@ var (
 
 	// ErrWrongType is returned from Key[T].Get() when the Binder contains a Binding for the Key's ID,
 	// but that Binding contains a value which is not of type T. This can only happen if 2 Keys are
-	// created with the same ID but different types.
+	// created with the same ID but different types; New() detects this with ErrKeyTypeConflict where
+	// the types of the keys used by tasks are known.
 	ErrWrongType = errors.New("wrong type")
 )
 
@@ -45,9 +48,131 @@ type Key[T any] interface {
 	BindError(err error) Binding
 }
 
+// TypedKey is an optional interface implemented by keys which know the type of the value bound to
+// their ID. Keys created with NewKey and NewNamespacedKey implement it.
+type TypedKey interface {
+	// Type returns the type of the value bound to the key.
+	Type() reflect.Type
+}
+
+// A KeyDescriptor describes a key which a task depends on or provides.
+type KeyDescriptor struct {
+	// ID of the key.
+	ID ID
+
+	// Type of the value bound to the key, or nil if it is not known (e.g. for tasks created with
+	// NewTask, which only declare the IDs of their keys).
+	Type reflect.Type
+
+	// Location where the key was defined, or an empty string if it is not known.
+	Location string
+
+	// Virtual is the location where the virtual key (see VirtualKey) through which the key is read
+	// was defined, or an empty string if the key is read directly.
+	Virtual string
+}
+
+// keyDescriber is implemented by virtual keys to describe the underlying keys which they read.
+type keyDescriber interface {
+	describe() []KeyDescriptor
+}
+
+// describeKey returns descriptors for the keys underlying k, which may be a Key, a ReadOnlyKey, or
+// a virtual key wrapping other keys.
+func describeKey(k any) []KeyDescriptor {
+	switch k := k.(type) {
+	case keyDescriber:
+		return k.describe()
+	case interface {
+		ID() ID
+		Location() string
+	}:
+		desc := KeyDescriptor{ID: k.ID(), Location: k.Location()}
+		if tk, ok := k.(TypedKey); ok {
+			desc.Type = tk.Type()
+		}
+		return []KeyDescriptor{desc}
+	}
+	return nil
+}
+
+// describeKeys returns descriptors for the keys underlying each of ks.
+func describeKeys[K any](ks ...K) []KeyDescriptor {
+	var res []KeyDescriptor
+	for _, k := range ks {
+		res = append(res, describeKey(k)...)
+	}
+	return res
+}
+
+// VirtualKey is an optional interface implemented by ReadOnlyKeys which derive their value from the
+// bindings of other keys, such as those created by Presence, Mapped, Optional and Combine2. Tasks
+// and conditions which read a VirtualKey depend on the IDs returned by DependencyIDs, rather than
+// the ID returned by the key's ID method.
+type VirtualKey interface {
+	// DependencyIDs returns the IDs of the bindings read by the key's Get method.
+	DependencyIDs() []ID
+}
+
+// DependencyIDs returns the IDs of the bindings read by the key's Get method: those returned by
+// DependencyIDs if the key is a VirtualKey, or its ID otherwise.
+func DependencyIDs[T any](key ReadOnlyKey[T]) []ID {
+	return keyDependencyIDs(key)
+}
+
+// keyDependencyIDs returns the IDs of the bindings read by the Get method of k, which may be a Key,
+// a ReadOnlyKey, or a virtual key wrapping other keys.
+func keyDependencyIDs(k any) []ID {
+	switch k := k.(type) {
+	case VirtualKey:
+		return k.DependencyIDs()
+	case interface{ ID() ID }:
+		return []ID{k.ID()}
+	}
+	return nil
+}
+
+// allPresent returns whether the bindings for all of the given IDs are present in the binder.
+func allPresent(b Binder, ids []ID) bool {
+	for _, id := range ids {
+		if b.Get(id).Status() != Present {
+			return false
+		}
+	}
+	return true
+}
+
+// describeVirtual returns descriptors for the keys underlying a virtual key, annotated with the
+// location of the virtual key.
+func describeVirtual(location string, keys ...any) []KeyDescriptor {
+	descs := describeKeys(keys...)
+	for i := range descs {
+		descs[i].Virtual = location
+	}
+	return descs
+}
+
+// describeIDs returns descs, plus untyped descriptors for any of ids which are not described by
+// descs.
+func describeIDs(ids []ID, descs []KeyDescriptor) []KeyDescriptor {
+	described := map[ID]bool{}
+	for _, d := range descs {
+		described[d.ID] = true
+	}
+	res := append([]KeyDescriptor{}, descs...)
+	for _, id := range ids {
+		if !described[id] {
+			described[id] = true
+			res = append(res, KeyDescriptor{ID: id})
+		}
+	}
+	return res
+}
+
 type key[T any] struct {
 	id       ID
 	location string
+	info     *keyInfo
 }
 
 func (k *key[T]) ID() ID {
@@ -58,16 +183,20 @@ func (k *key[T]) Location() string {
 	return k.location
 }
 
+func (k *key[T]) Type() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
 func (k *key[T]) Bind(val T) Binding {
-	return bind(k.id, val)
+	return withKeyInfo(bind(k.id, val), k.info)
 }
 
 func (k *key[T]) BindAbsent() Binding {
-	return bindAbsent(k.id)
+	return withKeyInfo(bindAbsent(k.id), k.info)
 }
 
 func (k *key[T]) BindError(err error) Binding {
-	return bindAbsentWithError(k.id, err)
+	return withKeyInfo(bindAbsentWithError(k.id, err), k.info)
 }
 
 func (k *key[T]) Get(b Binder) (T, error) {
@@ -96,21 +225,52 @@ func (k *key[T]) Get(b Binder) (T, error) {
 	}
 }
 
+// A KeyOption configures a Key created with NewKey or NewNamespacedKey.
+type KeyOption func(opts *keyOptions)
+
+type keyOptions struct {
+	codec      any
+	redact     bool
+	validators []func(any) error
+}
+
 // NewKey creates a new Key. This should typically be called at the top level of a package as a var.
-func NewKey[T any](id string) Key[T] {
-	return &key[T]{
-		id:       newID("", id),
-		location: getLocation(2),
-	}
+func NewKey[T any](id string, opts ...KeyOption) Key[T] {
+	return newKey[T](newID("", id), getLocation(2), opts)
 }
 
 // NewNamespacedKey creates a new namespaced Key. This should typically be called at the top level
 // of a package as a var.
-func NewNamespacedKey[T any](namespace, id string) Key[T] {
-	return &key[T]{
-		id:       newID(namespace, id),
-		location: getLocation(2),
+func NewNamespacedKey[T any](namespace, id string, opts ...KeyOption) Key[T] {
+	return newKey[T](newID(namespace, id), getLocation(2), opts)
+}
+
+// newKey creates a new Key, applying the given options. It panics if the options are not
+// consistent with the type of the key, as keys are expected to be created on program startup.
+func newKey[T any](id ID, location string, opts []KeyOption) Key[T] {
+	o := &keyOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	k := &key[T]{
+		id:       id,
+		location: location,
+	}
+	if o.codec != nil {
+		codec, ok := o.codec.(Codec[T])
+		if !ok {
+			panic(fmt.Sprintf("key %q (%s): %T is not a Codec[%s]", id, location, o.codec, k.Type()))
+		}
+		DefaultCodecs.register(id, typedCodec[T]{codec})
 	}
+	k.info = &keyInfo{
+		typ:        k.Type(),
+		location:   location,
+		redact:     o.redact,
+		validators: o.validators,
+	}
+	return k
 }
 
 type presenceKey[T any] struct {
@@ -122,11 +282,21 @@ func (k *presenceKey[T]) Location() string {
 	return k.location
 }
 
+func (k *presenceKey[T]) describe() []KeyDescriptor {
+	return describeVirtual(k.location, k.ReadOnlyKey)
+}
+
+func (k *presenceKey[T]) DependencyIDs() []ID {
+	return keyDependencyIDs(k.ReadOnlyKey)
+}
+
 func (k *presenceKey[T]) Get(b Binder) (bool, error) {
-	return b.Get(k.ID()).Status() == Present, nil
+	return allPresent(b, k.DependencyIDs()), nil
 }
 
-// Presence returns a ReadOnlyKey key which returns whether the underlying key is present in the binder.
+// Presence returns a ReadOnlyKey key which returns whether the underlying key is present in the
+// binder. If the underlying key is a VirtualKey, it returns whether all of the keys which it reads
+// are present.
 func Presence[T any](key ReadOnlyKey[T]) ReadOnlyKey[bool] {
 	return &presenceKey[T]{
 		ReadOnlyKey: key,
@@ -144,6 +314,14 @@ func (k *mappedKey[In, Out]) Location() string {
 	return k.location
 }
 
+func (k *mappedKey[In, Out]) describe() []KeyDescriptor {
+	return describeVirtual(k.location, k.ReadOnlyKey)
+}
+
+func (k *mappedKey[In, Out]) DependencyIDs() []ID {
+	return keyDependencyIDs(k.ReadOnlyKey)
+}
+
 func (k *mappedKey[In, Out]) Get(b Binder) (Out, error) {
 	val, err := k.ReadOnlyKey.Get(b)
 	if err != nil {
@@ -177,6 +355,14 @@ func (k *optionalKey[T]) Location() string {
 	return k.location
 }
 
+func (k *optionalKey[T]) describe() []KeyDescriptor {
+	return describeVirtual(k.location, k.ReadOnlyKey)
+}
+
+func (k *optionalKey[T]) DependencyIDs() []ID {
+	return keyDependencyIDs(k.ReadOnlyKey)
+}
+
 // Get must return an error to fulfil the ReadOnlyKey interface, but the error will always be nil.
 func (k *optionalKey[T]) Get(b Binder) (Maybe[T], error) {
 	return WrapMaybe(k.ReadOnlyKey.Get(b)), nil
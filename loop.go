@@ -0,0 +1,230 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	set "github.com/deckarep/golang-set/v2"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrLoopNotConverged is returned by a Loop task if its Until condition is not met within
+// MaxIterations iterations.
+var ErrLoopNotConverged = errors.New("loop did not converge")
+
+// Loop is a TaskSet containing a single task, which repeatedly runs the tasks in Body as a nested
+// graph until the Until condition evaluates to true. This allows "poll until ready" semantics, such
+// as waiting for a resource to report that it is ready.
+//
+// The task depends on every key which Body depends on but does not provide. On the first
+// iteration, these are read from the task's Binder; on subsequent iterations, the keys which are
+// values in Feedback are instead bound to the values bound by the previous iteration to the
+// corresponding keys in Feedback. Until is evaluated after each iteration, and may read both the
+// keys bound by Body and the task's dependencies.
+//
+// The task provides the keys in Exposes, bound to the values from the final iteration, and binds
+// Iterations (if set) to the number of iterations run. Each iteration is run in its own span.
+type Loop struct {
+	Name     string
+	Body     TaskSet
+	Feedback map[ID]ID
+	Until    ReadOnlyKey[bool]
+	Exposes  []ID
+
+	// Iterations, if set, is bound to the number of iterations run.
+	Iterations Key[int]
+
+	// MaxIterations is the maximum number of iterations to run; if Until has not evaluated to true
+	// by the final iteration, the task fails with ErrLoopNotConverged. It must be positive; New
+	// returns an error otherwise, or if Feedback or Exposes refer to keys which the body does not
+	// provide (or, for the values in Feedback, depend on).
+	MaxIterations int
+
+	// Interval is how long to wait between iterations. The loop stops early if the task's context
+	// is cancelled while waiting.
+	Interval time.Duration
+	location string
+}
+
+// Locate annotates the Loop with its location in the source code, to make error messages easier to
+// understand. Calling it is recommended.
+func (l Loop) Locate() Loop {
+	l.location = getLocation(2)
+	return l
+}
+
+const (
+	traceTaskgraphLoopPrefix = "taskgraph.loop."
+)
+
+// bodyDepends returns the keys which the body depends on but does not provide, and descriptors for
+// them, along with the keys which the body provides.
+func (l Loop) bodyDepends() ([]ID, []KeyDescriptor, set.Set[ID]) {
+	deps, provided := set.NewSet[ID](), set.NewSet[ID]()
+	var descs []KeyDescriptor
+	for _, t := range l.Body.Tasks() {
+		deps.Append(t.Depends()...)
+		provided.Append(t.Provides()...)
+		descs = append(descs, dependencyDescriptors(t)...)
+	}
+	external := deps.Difference(provided)
+	var externalDescs []KeyDescriptor
+	for _, d := range descs {
+		if external.Contains(d.ID) && d.Type != nil {
+			externalDescs = append(externalDescs, d)
+		}
+	}
+	res := external.ToSlice()
+	sortIDs(res)
+	return res, externalDescs, provided
+}
+
+// body creates the nested graph for the body, and validates the loop's configuration.
+func (l Loop) body(depends []ID, provided set.Set[ID]) (Graph, error) {
+	var errs error
+	if l.MaxIterations <= 0 {
+		errs = errors.Join(errs, wrapStackErrorf("MaxIterations must be positive"))
+	}
+	for from, to := range l.Feedback {
+		if !provided.Contains(from) || !slices.Contains(depends, to) {
+			errs = errors.Join(errs, wrapStackErrorf(
+				"feedback from %s to %s must be from a key provided by the body to a key it depends "+
+					"on but does not provide",
+				from,
+				to,
+			))
+		}
+	}
+	var missing []string
+	for _, id := range l.Exposes {
+		if !provided.Contains(id) {
+			missing = append(missing, id.String())
+		}
+	}
+	if len(missing) > 0 {
+		errs = errors.Join(errs, wrapStackErrorf(
+			"%w: %s",
+			ErrExposedKeyNotProvided,
+			strings.Join(missing, ", "),
+		))
+	}
+	body, err := New(l.Name, WithTasks(l.Body), WithTracer(contextTracer{}))
+	if err != nil {
+		errs = errors.Join(errs, err)
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return body, nil
+}
+
+// Tasks satisfies TaskSet.Tasks.
+func (l Loop) Tasks() []Task {
+	depends, dependDescs, provided := l.bodyDepends()
+	t := &task{
+		name:        l.Name,
+		depends:     depends,
+		provides:    append([]ID{}, l.Exposes...),
+		location:    l.location,
+		dependDescs: dependDescs,
+	}
+	// The body is created and validated once, so that New rejects an invalid loop.
+	body, err := l.body(depends, provided)
+	t.check = func() error { return err }
+	t.fn = func(ctx context.Context, b Binder) ([]Binding, error) {
+		if err != nil {
+			return nil, err
+		}
+		return l.execute(ctx, b, body, depends)
+	}
+	// The condition may read keys which are not provided by the body.
+	untilDepends := set.NewSet(keyDependencyIDs(l.Until)...).Difference(provided)
+	if untilDepends.Cardinality() > 0 {
+		t.depends = set.NewSet(depends...).Union(untilDepends).ToSlice()
+		sortIDs(t.depends)
+		t.dependDescs = append(t.dependDescs, describeKey(l.Until)...)
+	}
+	if l.Iterations != nil {
+		t.provides = append(t.provides, l.Iterations.ID())
+		t.provideDescs = describeKey(l.Iterations)
+	}
+	return []Task{t}
+}
+
+func (l Loop) execute(ctx context.Context, b Binder, body Graph, depends []ID) ([]Binding, error) {
+	inputs := map[ID]Binding{}
+	for _, id := range depends {
+		inputs[id] = b.Get(id)
+	}
+	for i := 0; ; i++ {
+		if i > 0 && l.Interval > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(l.Interval):
+			}
+		}
+
+		outputs, done, err := l.iterate(ctx, i, body, b, inputs)
+		if err != nil {
+			return nil, err
+		}
+		if done || i+1 == l.MaxIterations {
+			if !done {
+				return nil, wrapStackErrorf(
+					"loop %s: %w after %d iterations",
+					l.Name,
+					ErrLoopNotConverged,
+					l.MaxIterations,
+				)
+			}
+			var res []Binding
+			for _, id := range l.Exposes {
+				res = append(res, outputs.Get(id))
+			}
+			if l.Iterations != nil {
+				res = append(res, l.Iterations.Bind(i+1))
+			}
+			return res, nil
+		}
+
+		for from, to := range l.Feedback {
+			inputs[to] = rebind(outputs.Get(from), to)
+		}
+	}
+}
+
+// iterate runs a single iteration of the loop, returning the bindings produced by the body and
+// whether the Until condition evaluated to true.
+func (l Loop) iterate(
+	ctx context.Context,
+	i int,
+	body Graph,
+	b Binder,
+	inputs map[ID]Binding,
+) (Binder, bool, error) {
+	ctx, span := tracerFromContext(ctx).Start(ctx, fmt.Sprintf("%s[%d]", l.Name, i))
+	defer span.End()
+	span.SetAttributes(attribute.Int(traceTaskgraphLoopPrefix+"iteration", i))
+
+	var bindings []Binding
+	for _, binding := range inputs {
+		bindings = append(bindings, binding)
+	}
+	outputs, err := body.Run(ctx, bindings...)
+	if err != nil {
+		span.RecordError(err)
+		return nil, false, wrapStackErrorf("loop %s: iteration %d: %w", l.Name, i, err)
+	}
+	done, err := l.Until.Get(NewOverlayBinder(b, outputs))
+	if err != nil {
+		span.RecordError(err)
+		return nil, false, wrapStackErrorf("loop %s: iteration %d: %w", l.Name, i, err)
+	}
+	span.SetAttributes(attribute.Bool(traceTaskgraphLoopPrefix+"done", done))
+	return outputs, done, nil
+}
@@ -0,0 +1,151 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestLoop(t *testing.T) {
+	attempt := tg.NewKey[int]("attempt")
+	next := tg.NewKey[int]("next")
+	threshold := tg.NewKey[int]("threshold")
+	ready := tg.NewKey[bool]("ready")
+	iterations := tg.NewKey[int]("iterations")
+
+	body := tg.NewTaskSet(
+		tg.SimpleTask1[int, int]("poll", next, func(_ context.Context, i int) (int, error) {
+			return i + 1, nil
+		}, attempt),
+		tg.SimpleTask2[int, int, bool](
+			"check",
+			ready,
+			func(_ context.Context, n, threshold int) (bool, error) { return n >= threshold, nil },
+			next,
+			threshold,
+		),
+	)
+
+	loop := func(maxIterations int) tg.Loop {
+		return tg.Loop{
+			Name:          "loop",
+			Body:          body,
+			Feedback:      map[tg.ID]tg.ID{next.ID(): attempt.ID()},
+			Until:         ready,
+			Exposes:       []tg.ID{next.ID()},
+			Iterations:    iterations,
+			MaxIterations: maxIterations,
+			Interval:      time.Millisecond,
+		}.Locate()
+	}
+
+	tgt.Suite{
+		Tests: []tgt.Test{
+			{
+				Description: "converges",
+				Task:        loop(10),
+				Inputs:      []tg.Binding{attempt.Bind(0), threshold.Bind(3)},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(next.Bind(3)),
+					tgt.Match(iterations.Bind(3)),
+				},
+				CheckExcessBindings: true,
+			},
+			{
+				Description: "converges on first iteration",
+				Task:        loop(10),
+				Inputs:      []tg.Binding{attempt.Bind(5), threshold.Bind(3)},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(next.Bind(6)),
+					tgt.Match(iterations.Bind(1)),
+				},
+			},
+			{
+				Description: "does not converge",
+				Task:        loop(2),
+				Inputs:      []tg.Binding{attempt.Bind(0), threshold.Bind(3)},
+				WantError:   tg.ErrLoopNotConverged,
+			},
+		},
+	}.Run(t)
+}
+
+func TestLoopContextCancelled(t *testing.T) {
+	in := tg.NewKey[int]("in")
+	out := tg.NewKey[int]("out")
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(tg.Loop{
+		Name: "loop",
+		Body: tg.SimpleTask1[int, int]("identity", out, func(_ context.Context, i int) (int, error) {
+			return i, nil
+		}, in),
+		Until:         tg.Mapped(out, func(int) bool { return false }),
+		Exposes:       []tg.ID{out.ID()},
+		MaxIterations: 1000,
+		Interval:      time.Hour,
+	}.Locate())))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.Run(ctx, in.Bind(1)); err == nil {
+		t.Error("expected error when context is cancelled")
+	}
+}
+
+func TestLoopInvalid(t *testing.T) {
+	in := tg.NewKey[int]("in")
+	out := tg.NewKey[int]("out")
+	other := tg.NewKey[int]("other")
+	loop := func(modify func(*tg.Loop)) tg.Loop {
+		l := tg.Loop{
+			Name: "loop",
+			Body: tg.SimpleTask1[int, int]("identity", out, func(_ context.Context, i int) (int, error) {
+				return i, nil
+			}, in),
+			Feedback:      map[tg.ID]tg.ID{out.ID(): in.ID()},
+			Until:         tg.Mapped(out, func(int) bool { return true }),
+			Exposes:       []tg.ID{out.ID()},
+			MaxIterations: 10,
+		}.Locate()
+		modify(&l)
+		return l
+	}
+
+	for _, test := range []struct {
+		description string
+		loop        tg.Loop
+		wantErr     bool
+		wantErrIs   error
+	}{
+		{
+			description: "valid",
+			loop:        loop(func(*tg.Loop) {}),
+		},
+		{
+			description: "no iterations",
+			loop:        loop(func(l *tg.Loop) { l.MaxIterations = 0 }),
+			wantErr:     true,
+		},
+		{
+			description: "feedback from unprovided key",
+			loop:        loop(func(l *tg.Loop) { l.Feedback = map[tg.ID]tg.ID{other.ID(): in.ID()} }),
+			wantErr:     true,
+		},
+		{
+			description: "exposes unprovided key",
+			loop:        loop(func(l *tg.Loop) { l.Exposes = []tg.ID{other.ID()} }),
+			wantErr:     true,
+			wantErrIs:   tg.ErrExposedKeyNotProvided,
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			_, err := tg.New("test_graph", tg.WithTasks(test.loop))
+			if (err != nil) != test.wantErr || (test.wantErrIs != nil && !errors.Is(err, test.wantErrIs)) {
+				t.Errorf("got error %v; want error %t (wrapping %v)", err, test.wantErr, test.wantErrIs)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package taskgraph
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/errgroup"
+)
+
+// Map is a TaskSet containing a single task, which runs Fn for each item of the slice bound to
+// Input concurrently, and binds the results in the same order as the items. To run a sub-graph for
+// each item, use ItemGraph to create Fn.
+//
+// Exactly one of Output and MaybeOutput must be set (New returns an error otherwise). If Output is
+// set, an error returned by Fn for any item fails the task (and cancels the context passed to Fn
+// for the remaining items). If MaybeOutput is set, the result or error for every item is collected
+// instead.
+//
+// Each item is run in its own span, named after the task and the index of the item.
+type Map[In, Out any] struct {
+	Name        string
+	Input       ReadOnlyKey[[]In]
+	Fn          func(ctx context.Context, item In) (Out, error)
+	Output      Key[[]Out]
+	MaybeOutput Key[[]Maybe[Out]]
+
+	// Parallelism is the maximum number of items for which Fn is run concurrently. If zero or
+	// negative, Fn is run for all items concurrently.
+	Parallelism int
+	location    string
+}
+
+// Locate annotates the Map with its location in the source code, to make error messages easier to
+// understand. Calling it is recommended.
+func (m Map[In, Out]) Locate() Map[In, Out] {
+	m.location = getLocation(2)
+	return m
+}
+
+const (
+	traceTaskgraphMapPrefix = "taskgraph.map."
+)
+
+// Tasks satisfies TaskSet.Tasks.
+func (m Map[In, Out]) Tasks() []Task {
+	t := &task{
+		name:        m.Name,
+		depends:     keyDependencyIDs(m.Input),
+		fn:          m.execute,
+		location:    m.location,
+		dependDescs: describeKey(m.Input),
+	}
+	if m.Output != nil {
+		t.provides = append(t.provides, m.Output.ID())
+		t.provideDescs = append(t.provideDescs, describeKey(m.Output)...)
+	}
+	if m.MaybeOutput != nil {
+		t.provides = append(t.provides, m.MaybeOutput.ID())
+		t.provideDescs = append(t.provideDescs, describeKey(m.MaybeOutput)...)
+	}
+	if (m.Output == nil) == (m.MaybeOutput == nil) {
+		t.check = func() error {
+			return wrapStackErrorf("exactly one of Output and MaybeOutput must be set")
+		}
+	}
+	return []Task{t}
+}
+
+func (m Map[In, Out]) execute(ctx context.Context, b Binder) ([]Binding, error) {
+	items, err := m.Input.Get(b)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Maybe[Out], len(items))
+	eg, egCtx := errgroup.WithContext(ctx)
+	if m.Parallelism > 0 {
+		eg.SetLimit(m.Parallelism)
+	}
+	for i, item := range items {
+		eg.Go(func() error {
+			res, err := m.runItem(egCtx, i, item)
+			results[i] = WrapMaybe(res, err)
+			if m.Output != nil && err != nil {
+				return wrapStackErrorf("map %s: item %d: %w", m.Name, i, err)
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	if m.MaybeOutput != nil {
+		return []Binding{m.MaybeOutput.Bind(results)}, nil
+	}
+	out := make([]Out, len(results))
+	for i, res := range results {
+		out[i], _ = res.Get()
+	}
+	return []Binding{m.Output.Bind(out)}, nil
+}
+
+func (m Map[In, Out]) runItem(ctx context.Context, i int, item In) (Out, error) {
+	ctx = withPlanPosition(ctx, i)
+	ctx, span := tracerFromContext(ctx).Start(ctx, fmt.Sprintf("%s[%d]", m.Name, i))
+	defer span.End()
+	span.SetAttributes(attribute.Int(traceTaskgraphMapPrefix+"index", i))
+
+	res, err := m.Fn(ctx, item)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return res, err
+}
+
+// ItemGraph returns a function for use as the Fn of a Map, which runs the graph for each item with
+// the item bound to itemKey, and returns the value bound to resultKey.
+func ItemGraph[In, Out any](
+	g Graph,
+	itemKey Key[In],
+	resultKey ReadOnlyKey[Out],
+) func(ctx context.Context, item In) (Out, error) {
+	return func(ctx context.Context, item In) (Out, error) {
+		b, err := g.Run(ctx, itemKey.Bind(item))
+		if err != nil {
+			var empty Out
+			return empty, err
+		}
+		return resultKey.Get(b)
+	}
+}
@@ -0,0 +1,144 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestMap(t *testing.T) {
+	items := tg.NewKey[[]int]("items")
+	out := tg.NewKey[[]string]("out")
+	maybeOut := tg.NewKey[[]tg.Maybe[string]]("maybe_out")
+	sentinelError := errors.New("sentinel error")
+
+	format := func(_ context.Context, i int) (string, error) {
+		if i < 0 {
+			return "", sentinelError
+		}
+		return fmt.Sprint(i), nil
+	}
+
+	itemKey := tg.NewKey[int]("item")
+	itemResult := tg.NewKey[string]("item_result")
+	itemGraph := tgt.Must[tg.Graph](t)(tg.New("item_graph", tg.WithTasks(
+		tg.SimpleTask1[int, string]("format", itemResult, format, itemKey),
+	)))
+
+	tgt.Suite{
+		Tests: []tgt.Test{
+			{
+				Description: "results in input order",
+				Task: tg.Map[int, string]{
+					Name:   "map",
+					Input:  items,
+					Fn:     format,
+					Output: out,
+				}.Locate(),
+				Inputs: []tg.Binding{items.Bind([]int{3, 1, 2})},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(out.Bind([]string{"3", "1", "2"})),
+				},
+				CheckExcessBindings:     true,
+				StrictDependencies:      true,
+				CheckUnusedDependencies: true,
+			},
+			{
+				Description: "item error fails task",
+				Task: tg.Map[int, string]{
+					Name:   "map",
+					Input:  items,
+					Fn:     format,
+					Output: out,
+				}.Locate(),
+				Inputs:    []tg.Binding{items.Bind([]int{1, -1})},
+				WantError: sentinelError,
+			},
+			{
+				Description: "item errors collected",
+				Task: tg.Map[int, string]{
+					Name:        "map",
+					Input:       items,
+					Fn:          format,
+					MaybeOutput: maybeOut,
+				}.Locate(),
+				Inputs: []tg.Binding{items.Bind([]int{1, -1})},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(maybeOut.Bind([]tg.Maybe[string]{
+						tg.MaybeOf("1"),
+						tg.MaybeErr[string](sentinelError),
+					}), tgt.TransformMaybe[string](), cmpopts.EquateErrors()),
+				},
+			},
+			{
+				Description: "item graph",
+				Task: tg.Map[int, string]{
+					Name:   "map",
+					Input:  items,
+					Fn:     tg.ItemGraph[int, string](itemGraph, itemKey, itemResult),
+					Output: out,
+				}.Locate(),
+				Inputs: []tg.Binding{items.Bind([]int{4, 5})},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(out.Bind([]string{"4", "5"})),
+				},
+			},
+		},
+	}.Run(t)
+}
+
+func TestMapParallelism(t *testing.T) {
+	items := tg.NewKey[[]int]("items")
+	out := tg.NewKey[[]int]("out")
+
+	var running, maxRunning atomic.Int32
+	task := tg.Map[int, int]{
+		Name:  "map",
+		Input: items,
+		Fn: func(_ context.Context, i int) (int, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			return i * 2, nil
+		},
+		Output:      out,
+		Parallelism: 2,
+	}.Locate()
+
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(task)))
+	res, err := g.Run(context.Background(), items.Bind([]int{1, 2, 3, 4, 5, 6}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt.ExpectPresent(t, res, out, []int{2, 4, 6, 8, 10, 12})
+	if maxRunning.Load() > 2 {
+		t.Errorf("expected at most 2 items to run concurrently; got %d", maxRunning.Load())
+	}
+}
+
+func TestMapInvalidOutputs(t *testing.T) {
+	items := tg.NewKey[[]int]("items")
+	out := tg.NewKey[[]string]("out")
+	maybeOut := tg.NewKey[[]tg.Maybe[string]]("maybe_out")
+	format := func(_ context.Context, i int) (string, error) { return "", nil }
+
+	for _, m := range []tg.Map[int, string]{
+		{Name: "neither", Input: items, Fn: format},
+		{Name: "both", Input: items, Fn: format, Output: out, MaybeOutput: maybeOut},
+	} {
+		if _, err := tg.New("test_graph", tg.WithTasks(m.Locate())); err == nil {
+			t.Errorf("%s: expected New to fail", m.Name)
+		}
+	}
+}
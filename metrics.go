@@ -14,9 +14,20 @@ var executionLatency = prometheus.NewHistogramVec(
 	}, []string{"graph", "result"},
 )
 
+// cacheRequests counts lookups of cached task bindings (see Cached), by whether the lookup was a
+// hit or a miss.
+var cacheRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "taskgraph",
+		Name:      "cache_requests_total",
+		Help:      "Number of lookups of cached task bindings",
+	}, []string{"task", "result"},
+)
+
 // RegisterMetrics registers all taskgraph metrics with a prometheus registry.
 func RegisterMetrics(registry prometheus.Registerer) {
 	registry.MustRegister(
 		executionLatency,
+		cacheRequests,
 	)
 }
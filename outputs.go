@@ -0,0 +1,126 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	set "github.com/deckarep/golang-set/v2"
+)
+
+// ErrNoOutputs is returned by Graph.RunOutputs if the graph has no outputs declared with
+// WithOutputs.
+var ErrNoOutputs = errors.New("graph has no declared outputs")
+
+// WithOutputs declares the public outputs of the graph, which are the only bindings returned by
+// Graph.RunOutputs. Each of the IDs must be provided by a task in the graph; New returns
+// ErrExposedKeyNotProvided otherwise.
+func WithOutputs(ids ...ID) GraphOption {
+	return func(opts *graphOptions) error {
+		opts.outputs = append(opts.outputs, ids...)
+
+		return nil
+	}
+}
+
+// WithEarlyStop makes Graph.RunOutputs stop the run as soon as all of the outputs declared with
+// WithOutputs are bound and every side-effecting task (see SideEffect) has completed, cancelling
+// the context of any tasks which are still running. Tasks which provide no keys are also considered
+// to be side-effecting, as that is their only purpose.
+//
+// Graph.Run and Graph.Resume always run every task.
+func WithEarlyStop() GraphOption {
+	return func(opts *graphOptions) error {
+		opts.earlyStop = true
+
+		return nil
+	}
+}
+
+// checkOutputs checks that the declared outputs are provided by the graph.
+func (g *graph) checkOutputs() error {
+	if g.earlyStop && len(g.outputs) == 0 {
+		return wrapStackErrorf("WithEarlyStop requires outputs to be declared with WithOutputs")
+	}
+	var missing []string
+	for _, id := range g.outputs {
+		if !g.allProvided.Contains(id) {
+			missing = append(missing, id.String())
+		}
+	}
+	if len(missing) > 0 {
+		return wrapStackErrorf("%w: %s", ErrExposedKeyNotProvided, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// RunOutputs is Graph.RunOutputs.
+func (g *graph) RunOutputs(ctx context.Context, inputs ...Binding) (Binder, error) {
+	if len(g.outputs) == 0 {
+		return nil, wrapStackErrorf("%w: %s", ErrNoOutputs, g.name)
+	}
+	b, err := g.run(ctx, runConfig{outputsOnly: true}, inputs...)
+	if err != nil {
+		return nil, err
+	}
+	res := NewBinder()
+	for _, id := range g.outputs {
+		if err := res.Store(b.Get(id)); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// earlyStop tracks whether a run can be stopped early; see WithEarlyStop.
+type earlyStop struct {
+	// Protects against concurrent access to pending
+	sync.Mutex
+
+	outputs []ID
+	// pending contains the names of the side-effecting tasks which have not completed.
+	pending set.Set[string]
+	done    chan struct{}
+}
+
+func (g *graph) newEarlyStop() *earlyStop {
+	es := &earlyStop{
+		outputs: g.outputs,
+		pending: set.NewThreadUnsafeSet[string](),
+		done:    make(chan struct{}),
+	}
+	// Finally tasks are not included, as they are only run once the run has stopped.
+	for _, node := range g.nodes {
+		if isSideEffecting(node.task) {
+			es.pending.Add(node.task.Name())
+		}
+	}
+	return es
+}
+
+// taskDone records that the task has completed, and closes the done channel if the run can now be
+// stopped.
+func (es *earlyStop) taskDone(b Binder, t Task) {
+	es.Lock()
+	defer es.Unlock()
+
+	es.pending.Remove(t.Name())
+	if es.pending.Cardinality() > 0 || !b.Has(es.outputs...) {
+		return
+	}
+	select {
+	case <-es.done:
+	default:
+		close(es.done)
+	}
+}
+
+// stopped returns a channel which is closed when the run can be stopped early, or nil if the run
+// cannot be stopped early.
+func (rs *runState) stopped() <-chan struct{} {
+	if rs.earlyStop == nil {
+		return nil
+	}
+	return rs.earlyStop.done
+}
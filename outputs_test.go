@@ -0,0 +1,154 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestRunOutputs(t *testing.T) {
+	keyA := tg.NewKey[int]("a")
+	keyB := tg.NewKey[int]("b")
+
+	g := tgt.Must[tg.Graph](t)(tg.New(
+		"test_graph",
+		tg.WithTasks(
+			tg.SimpleTask[int]("a", keyA, func(context.Context, tg.Binder) (int, error) {
+				return 1, nil
+			}),
+			tg.SimpleTask1[int, int]("b", keyB, func(_ context.Context, a int) (int, error) {
+				return a + 1, nil
+			}, keyA),
+		),
+		tg.WithOutputs(keyB.ID()),
+	))
+
+	b, err := g.RunOutputs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the declared output is returned.
+	tgt.ExpectExactBindings(t, b, []tgt.BindingMatcher{
+		tgt.Match(keyB.Bind(2)),
+	})
+}
+
+func TestRunOutputsWithoutOutputs(t *testing.T) {
+	keyA := tg.NewKey[int]("a")
+	ran := false
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(
+		tg.SimpleTask[int]("a", keyA, func(context.Context, tg.Binder) (int, error) {
+			ran = true
+			return 1, nil
+		}),
+	)))
+
+	if _, err := g.RunOutputs(context.Background()); !errors.Is(err, tg.ErrNoOutputs) {
+		t.Errorf("got error %v; want %v", err, tg.ErrNoOutputs)
+	}
+	if ran {
+		t.Error("expected no tasks to run")
+	}
+}
+
+func TestWithOutputsInvalid(t *testing.T) {
+	keyA := tg.NewKey[int]("a")
+	keyB := tg.NewKey[int]("b")
+	task := tg.SimpleTask[int]("a", keyA, func(context.Context, tg.Binder) (int, error) {
+		return 1, nil
+	})
+
+	for _, test := range []struct {
+		description string
+		opts        []tg.GraphOption
+		wantErr     error
+	}{
+		{
+			description: "output not provided",
+			opts:        []tg.GraphOption{tg.WithOutputs(keyB.ID())},
+			wantErr:     tg.ErrExposedKeyNotProvided,
+		},
+		{
+			description: "early stop without outputs",
+			opts:        []tg.GraphOption{tg.WithEarlyStop()},
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			_, err := tg.New("test_graph", append([]tg.GraphOption{tg.WithTasks(task)}, test.opts...)...)
+			if err == nil || (test.wantErr != nil && !errors.Is(err, test.wantErr)) {
+				t.Errorf("got error %v; want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestEarlyStop(t *testing.T) {
+	keyA := tg.NewKey[int]("a")
+	keyC := tg.NewKey[int]("c")
+
+	var notified, reported atomic.Bool
+	slowCancelled := make(chan struct{})
+	g := tgt.Must[tg.Graph](t)(tg.New(
+		"test_graph",
+		tg.WithTasks(
+			tg.SimpleTask[int]("a", keyA, func(context.Context, tg.Binder) (int, error) {
+				return 1, nil
+			}),
+			// Side-effecting, so must complete before the run stops.
+			tg.NoOutputTask("notify", func(context.Context, tg.Binder) error {
+				time.Sleep(10 * time.Millisecond)
+				notified.Store(true)
+				return nil
+			}, keyA.ID()),
+			tg.SimpleTask[int]("slow", keyC, func(ctx context.Context, _ tg.Binder) (int, error) {
+				<-ctx.Done()
+				close(slowCancelled)
+				return 0, ctx.Err()
+			}),
+			// Runs once the run has stopped, so does not delay stopping it.
+			tg.Finally{
+				Wrapped: tg.NoOutputTask("report", func(context.Context, tg.Binder) error {
+					reported.Store(true)
+					return nil
+				}),
+			}.Locate(),
+		),
+		tg.WithOutputs(keyA.ID()),
+		tg.WithEarlyStop(),
+	))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	goroutines := runtime.NumGoroutine()
+	b, err := g.RunOutputs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt.ExpectPresent(t, b, keyA, 1)
+	if !notified.Load() {
+		t.Error("expected side-effecting task to complete before the run stopped")
+	}
+	if !reported.Load() {
+		t.Error("expected finally task to run")
+	}
+	select {
+	case <-slowCancelled:
+	case <-ctx.Done():
+		t.Error("expected remaining task to be cancelled")
+	}
+
+	// The goroutines of the run exit once the remaining task has been cancelled.
+	for runtime.NumGoroutine() > goroutines {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("got %d goroutines after the run; want %d", runtime.NumGoroutine(), goroutines)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrNotPlanned is the error with which the keys provided by a side-effecting task without a plan
+// function are bound as absent by Graph.Plan.
+var ErrNotPlanned = errors.New("side-effecting task not run when planning")
+
+// PlanFunc predicts the bindings which a side-effecting task would return, without performing the
+// side effects, along with a human-readable description of the action which the task would take.
+type PlanFunc func(ctx context.Context, b Binder) ([]Binding, string, error)
+
+// SideEffect is a TaskSet which marks the tasks in Wrapped as side-effecting, i.e. tasks which
+// mutate the world rather than only computing their bindings. Side-effecting tasks are not run by
+// Graph.Plan; Plan is called instead (if set), to predict the bindings which the task would return.
+// If Plan is not set, the task's keys are bound as absent with ErrNotPlanned.
+//
+// Tasks which provide no keys are side-effecting without being wrapped in a SideEffect, and are not
+// run by Graph.Plan either; they are planned without a description. As they are not run, they are
+// planned even if they would be skipped (e.g. if wrapped in a Conditional), so wrapping them in a
+// SideEffect is recommended.
+//
+// Wrapping a SideEffect in a Cached is not recommended, as predicted bindings would be cached.
+type SideEffect struct {
+	Wrapped  TaskSet
+	Plan     PlanFunc
+	location string
+}
+
+// Locate annotates the SideEffect with its location in the source code, to make error messages
+// easier to understand. Calling it is recommended.
+func (s SideEffect) Locate() SideEffect {
+	s.location = getLocation(2)
+	return s
+}
+
+// Tasks satisfies TaskSet.Tasks.
+func (s SideEffect) Tasks() []Task {
+	var res []Task
+	for _, t := range s.Wrapped.Tasks() {
+		// t is captured by the fn closure below
+		t := t
+		st := copyTask(t)
+		if s.location != "" {
+			st.location = s.location
+		}
+		st.sideEffect = true
+		st.fn = func(ctx context.Context, b Binder) ([]Binding, error) {
+			pr := planRecorderFromContext(ctx)
+			if pr == nil {
+				return t.Execute(ctx, b)
+			}
+			if s.Plan == nil {
+				var res []Binding
+				for _, id := range t.Provides() {
+					res = append(res, bindAbsentWithError(id, ErrNotPlanned))
+				}
+				pr.record(ctx, st, "", res)
+				return res, nil
+			}
+			res, description, err := s.Plan(ctx, b)
+			if err != nil {
+				return nil, err
+			}
+			pr.record(ctx, st, description, res)
+			return res, nil
+		}
+		res = append(res, st)
+	}
+	return res
+}
+
+// isSideEffecting returns whether the task mutates the world, which is the case for tasks wrapped
+// in a SideEffect, and tasks which provide no keys (as that is their only purpose).
+func isSideEffecting(t Task) bool {
+	if tt, ok := t.(*task); ok && tt.sideEffect {
+		return true
+	}
+	return len(t.Provides()) == 0
+}
+
+// A PlannedAction describes a side-effecting task which would be run by a graph.
+type PlannedAction struct {
+	// Task is the name of the task.
+	Task string
+	// Location is the location of the task.
+	Location string
+	// Description is the description returned by the task's plan function, or an empty string if
+	// it does not have one.
+	Description string
+	// Bindings are the bindings predicted by the task's plan function.
+	Bindings []Binding
+}
+
+// A PlanReport is returned by Graph.Plan.
+type PlanReport struct {
+	// Actions are the side-effecting tasks which would be run, in an order consistent with the
+	// dependencies between them. Independent tasks are ordered as they were passed to New.
+	Actions []PlannedAction
+	// Bindings contains the bindings from all tasks, including the predicted bindings of
+	// side-effecting tasks.
+	Bindings Binder
+}
+
+// String returns a human-readable list of the actions in the plan.
+func (pr *PlanReport) String() string {
+	var sb strings.Builder
+	for i, a := range pr.Actions {
+		description := a.Description
+		if description == "" {
+			description = "(no plan)"
+		}
+		fmt.Fprintf(&sb, "%d. %s (%s): %s\n", i+1, a.Task, a.Location, description)
+	}
+	return sb.String()
+}
+
+// planRecorder collects the actions of side-effecting tasks during a call to Graph.Plan.
+type planRecorder struct {
+	// Protects against concurrent access to actions
+	sync.Mutex
+
+	actions []plannedAction
+}
+
+// plannedAction is a PlannedAction along with its position (see withPlanPosition), by which the
+// actions are sorted.
+type plannedAction struct {
+	PlannedAction
+	position []int
+}
+
+func (pr *planRecorder) record(
+	ctx context.Context,
+	t Task,
+	description string,
+	bindings []Binding,
+) {
+	pr.Lock()
+	defer pr.Unlock()
+
+	pr.actions = append(pr.actions, plannedAction{
+		PlannedAction: PlannedAction{
+			Task:        t.Name(),
+			Location:    t.Location(),
+			Description: description,
+			Bindings:    bindings,
+		},
+		position: planPositionFromContext(ctx),
+	})
+}
+
+// sortedActions returns the recorded actions, sorted by their positions. Actions with the same
+// position (e.g. from successive iterations of a Loop) are kept in the order they were recorded.
+func (pr *planRecorder) sortedActions() []PlannedAction {
+	pr.Lock()
+	defer pr.Unlock()
+
+	slices.SortStableFunc(pr.actions, func(a, b plannedAction) int {
+		return slices.Compare(a.position, b.position)
+	})
+	res := make([]PlannedAction, len(pr.actions))
+	for i, a := range pr.actions {
+		res[i] = a.PlannedAction
+	}
+	return res
+}
+
+type planRecorderContextKey struct{}
+
+// planRecorderFromContext returns the planRecorder of the Graph.Plan call in which the context was
+// created, or nil if the graph is being run.
+func planRecorderFromContext(ctx context.Context) *planRecorder {
+	pr, _ := ctx.Value(planRecorderContextKey{}).(*planRecorder)
+	return pr
+}
+
+type planPositionContextKey struct{}
+
+// withPlanPosition returns a context for running the i'th of a set of tasks (or items of a Map)
+// when planning. The position of an action is the list of indexes of the task which records it and
+// those of the nested graphs and items which contain the task.
+func withPlanPosition(ctx context.Context, i int) context.Context {
+	if planRecorderFromContext(ctx) == nil {
+		return ctx
+	}
+	position := slices.Clone(planPositionFromContext(ctx))
+	return context.WithValue(ctx, planPositionContextKey{}, append(position, i))
+}
+
+func planPositionFromContext(ctx context.Context) []int {
+	position, _ := ctx.Value(planPositionContextKey{}).([]int)
+	return position
+}
+
+// planUnmarkedSideEffect records the task if it is side-effecting but not wrapped in a SideEffect
+// (i.e. it provides no keys) and the graph is being planned, returning whether it was recorded, in
+// which case it must not be run.
+func planUnmarkedSideEffect(ctx context.Context, t Task) bool {
+	pr := planRecorderFromContext(ctx)
+	if pr == nil || !isSideEffecting(t) {
+		return false
+	}
+	if tt, ok := t.(*task); ok && tt.sideEffect {
+		return false
+	}
+	pr.record(ctx, t, "", nil)
+	return true
+}
+
+// Plan is Graph.Plan.
+func (g *graph) Plan(ctx context.Context, inputs ...Binding) (*PlanReport, error) {
+	pr := &planRecorder{}
+	ctx = context.WithValue(ctx, planRecorderContextKey{}, pr)
+	b, err := g.run(ctx, runConfig{}, inputs...)
+	if err != nil {
+		return nil, err
+	}
+	return &PlanReport{Actions: pr.sortedActions(), Bindings: b}, nil
+}
@@ -0,0 +1,201 @@
+package taskgraph_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func actionNames(report *tg.PlanReport) []string {
+	var res []string
+	for _, a := range report.Actions {
+		res = append(res, a.Task)
+	}
+	return res
+}
+
+func TestPlan(t *testing.T) {
+	name := tg.NewKey[string]("name")
+	existing := tg.NewKey[bool]("existing")
+	created := tg.NewKey[string]("created")
+	notified := tg.NewKey[bool]("notified")
+
+	var ran []string
+	g := tgt.Must[tg.Graph](t)(tg.New(
+		"test_graph",
+		tg.WithTasks(
+			tg.SimpleTask1[string, bool](
+				"lookup",
+				existing,
+				func(_ context.Context, n string) (bool, error) { return n == "existing", nil },
+				name,
+			),
+			tg.Conditional{
+				Wrapped: tg.SideEffect{
+					Wrapped: tg.SimpleTask1[string, string](
+						"create",
+						created,
+						func(_ context.Context, n string) (string, error) {
+							ran = append(ran, "create")
+							return "id-" + n, nil
+						},
+						name,
+					),
+					Plan: func(_ context.Context, b tg.Binder) ([]tg.Binding, string, error) {
+						n, err := name.Get(b)
+						if err != nil {
+							return nil, "", err
+						}
+						return []tg.Binding{created.Bind("<new>")}, fmt.Sprintf("create %s", n), nil
+					},
+				}.Locate(),
+				Condition: tg.NotCond(tg.ConditionAnd{existing}),
+			}.Locate(),
+			tgt.Must[tg.TaskSet](t)(tg.NewMultiTaskBuilder("notify").
+				DependsOn(created).
+				Provides(notified).
+				Run(func(string) []tg.Binding {
+					ran = append(ran, "notify")
+					return []tg.Binding{notified.Bind(true)}
+				}).
+				SideEffect(nil).
+				Build()),
+			// Provides no keys, so is side-effecting without being wrapped in a SideEffect.
+			tg.NoOutputTask("audit", func(context.Context, tg.Binder) error {
+				ran = append(ran, "audit")
+				return nil
+			}, notified.ID()),
+			// Runs after the other tasks, so is planned last.
+			tg.Finally{
+				Wrapped: tg.NoOutputTask("report", func(context.Context, tg.Binder) error {
+					ran = append(ran, "report")
+					return nil
+				}, tg.RunResultKey.ID()),
+			}.Locate(),
+		),
+	))
+
+	for _, test := range []struct {
+		description string
+		name        string
+		wantActions []string
+		wantCreated bool
+	}{
+		{
+			description: "new",
+			name:        "new",
+			wantActions: []string{"create", "notify", "audit", "report"},
+			wantCreated: true,
+		},
+		{
+			description: "existing",
+			name:        "existing",
+			// Side-effecting tasks which would not run are not planned.
+			wantActions: []string{"notify", "audit", "report"},
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			ran = nil
+			report, err := g.Plan(context.Background(), name.Bind(test.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ran) > 0 {
+				t.Errorf("expected side-effecting tasks not to run; ran %v", ran)
+			}
+			if diff := cmp.Diff(test.wantActions, actionNames(report)); diff != "" {
+				t.Errorf("unexpected actions (-want +got):\n%s", diff)
+			}
+			if !strings.Contains(report.String(), "(no plan)") {
+				t.Errorf("expected tasks without a plan function in report; got:\n%s", report)
+			}
+			if test.wantCreated {
+				tgt.ExpectPresent(t, report.Bindings, created, "<new>")
+			}
+			tgt.ExpectAbsentError(t, report.Bindings, notified, tg.ErrNotPlanned)
+		})
+	}
+
+	t.Run("Run", func(t *testing.T) {
+		ran = nil
+		if _, err := g.Run(context.Background(), name.Bind("new")); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"create", "notify", "audit", "report"}, ran); diff != "" {
+			t.Errorf("unexpected tasks run (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestPlanOrder(t *testing.T) {
+	first := tg.NewKey[bool]("first")
+	second := tg.NewKey[bool]("second")
+
+	sideEffect := func(
+		name string,
+		key tg.Key[bool],
+		delay time.Duration,
+		depends ...tg.ID,
+	) tg.TaskSet {
+		return tg.SideEffect{
+			Wrapped: tg.NewTask(name, tgt.DummyTaskFunc(key.Bind(true)), depends, []tg.ID{key.ID()}),
+			Plan: func(context.Context, tg.Binder) ([]tg.Binding, string, error) {
+				time.Sleep(delay)
+				return []tg.Binding{key.Bind(true)}, name, nil
+			},
+		}.Locate()
+	}
+
+	for _, test := range []struct {
+		description string
+		tasks       []tg.TaskSet
+		wantActions []string
+	}{
+		{
+			description: "independent tasks in the order passed to New",
+			tasks: []tg.TaskSet{
+				// Planned last, but listed first.
+				sideEffect("first", first, 10*time.Millisecond),
+				sideEffect("second", second, 0),
+			},
+			wantActions: []string{"first", "second"},
+		},
+		{
+			description: "dependent tasks after their dependencies",
+			tasks: []tg.TaskSet{
+				sideEffect("second", second, 0, first.ID()),
+				sideEffect("first", first, 0),
+			},
+			wantActions: []string{"first", "second"},
+		},
+		{
+			description: "nested graphs",
+			tasks: []tg.TaskSet{
+				tgt.Must[tg.Task](t)(tgt.Must[tg.Graph](t)(tg.New(
+					"inner",
+					tg.WithTasks(sideEffect("first", first, 10*time.Millisecond)),
+				)).AsTask(first.ID())),
+				sideEffect("second", second, 0),
+			},
+			wantActions: []string{"first", "second"},
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(test.tasks...)))
+			report, err := g.Plan(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.wantActions, actionNames(report)); diff != "" {
+				t.Errorf("unexpected actions (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -214,11 +214,10 @@ func newReflectFn(fn any, resultType reflect.Type, deps ...any) (rf *reflectFn,
 			)
 		}
 		keys = append(keys, rk)
-		id, err := rk.ID()
-		if err != nil {
+		if _, err := rk.ID(); err != nil {
 			return nil, wrapStackErrorf("dependency %d: %w", i, err)
 		}
-		depIDs = append(depIDs, id)
+		depIDs = append(depIDs, keyDependencyIDs(dep)...)
 	}
 
 	return &reflectFn{
@@ -299,7 +298,10 @@ func (r Reflect[T]) Build() (Task, error) {
 			}
 			return []Binding{r.ResultKey.Bind(typed)}, nil
 		},
-		location: r.location,
+		location:     r.location,
+		userFn:       r.Fn,
+		dependDescs:  describeKeys(r.Depends...),
+		provideDescs: describeKey(r.ResultKey),
 	}, nil
 }
 
@@ -332,6 +334,10 @@ type ReflectMulti struct {
 	Depends []any
 
 	location string
+
+	// provideDescs describes the keys in Provides, where they are known (e.g. when built by a
+	// MultiTaskBuilder).
+	provideDescs []KeyDescriptor
 }
 
 // Locate annotates the ReflectMulti with its location in the source code, to make error messages
@@ -372,7 +378,10 @@ func (r ReflectMulti) Build() (Task, error) {
 			}
 			return typed, nil
 		},
-		location: r.location,
+		location:     r.location,
+		userFn:       r.Fn,
+		dependDescs:  describeKeys(r.Depends...),
+		provideDescs: r.provideDescs,
 	}, nil
 }
 
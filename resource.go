@@ -0,0 +1,167 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrUnknownResource is returned by a task which uses a resource which has not been added to the
+// graph (or any graph containing it) with WithResource.
+var ErrUnknownResource = errors.New("unknown resource")
+
+// A ResourceLimiter limits the use of a resource by tasks. It is satisfied by
+// *golang.org/x/sync/semaphore.Weighted; rate limits can be implemented by acquiring tokens from a
+// rate limiter in Acquire, and ignoring Release.
+type ResourceLimiter interface {
+	// Acquire blocks until n tokens are available, or the context is done (in which case it returns
+	// the context's error).
+	Acquire(ctx context.Context, n int64) error
+
+	// Release returns n tokens acquired with Acquire.
+	Release(n int64)
+}
+
+// WithResource adds a named pool of tokens to the graph, which tasks consume while they run (see
+// UsesResources). The pool is shared by all concurrent runs of the graph, and by the tasks of any
+// graphs nested within it (e.g. with AsTask); if a nested graph also has a pool with the same name,
+// the pool of the outer graph is used.
+func WithResource(name string, limiter ResourceLimiter) GraphOption {
+	return func(opts *graphOptions) error {
+		if opts.resources == nil {
+			opts.resources = map[string]ResourceLimiter{}
+		}
+		opts.resources[name] = limiter
+
+		return nil
+	}
+}
+
+// UsesResources is a TaskSet which declares the resources consumed by each of the tasks in Wrapped,
+// as the number of tokens used from each named pool (see WithResource). When a task is run, it
+// waits until the tokens are available, and releases them once it completes. The numbers of tokens
+// must be positive (New returns an error otherwise).
+//
+// The tokens are only held while the wrapped task is executed, so a UsesResources should be wrapped
+// in a Conditional or Cached (rather than wrapping them), so that tasks which are skipped or whose
+// results are cached do not wait for tokens.
+type UsesResources struct {
+	Wrapped   TaskSet
+	Resources map[string]int64
+	location  string
+}
+
+// Locate annotates the UsesResources with its location in the source code, to make error messages
+// easier to understand. Calling it is recommended.
+func (u UsesResources) Locate() UsesResources {
+	u.location = getLocation(2)
+	return u
+}
+
+// Tasks satisfies TaskSet.Tasks.
+func (u UsesResources) Tasks() []Task {
+	var res []Task
+	for _, t := range u.Wrapped.Tasks() {
+		// t is captured by the fn closure below
+		t := t
+		ut := copyTask(t)
+		if u.location != "" {
+			ut.location = u.location
+		}
+		if check := ut.check; check != nil {
+			ut.check = func() error {
+				return errors.Join(check(), u.check())
+			}
+		} else {
+			ut.check = u.check
+		}
+		ut.fn = func(ctx context.Context, b Binder) ([]Binding, error) {
+			release, err := acquireResources(ctx, u.Resources)
+			if err != nil {
+				return nil, err
+			}
+			defer release()
+			return t.Execute(ctx, b)
+		}
+		res = append(res, ut)
+	}
+	return res
+}
+
+// check returns an error for each resource of which a non-positive number of tokens is used.
+func (u UsesResources) check() error {
+	var errs error
+	for _, name := range slices.Sorted(maps.Keys(u.Resources)) {
+		if n := u.Resources[name]; n <= 0 {
+			errs = errors.Join(errs, wrapStackErrorf(
+				"resource %s: number of tokens must be positive; got %d",
+				name,
+				n,
+			))
+		}
+	}
+	return errs
+}
+
+type resourcesContextKey struct{}
+
+// withResources returns a context containing the graph's resource pools, in addition to those of
+// the graphs in which the context was created (which take precedence).
+func withResources(ctx context.Context, resources map[string]ResourceLimiter) context.Context {
+	if len(resources) == 0 {
+		return ctx
+	}
+	merged := maps.Clone(resources)
+	maps.Copy(merged, resourcesFromContext(ctx))
+	return context.WithValue(ctx, resourcesContextKey{}, merged)
+}
+
+func resourcesFromContext(ctx context.Context) map[string]ResourceLimiter {
+	resources, _ := ctx.Value(resourcesContextKey{}).(map[string]ResourceLimiter)
+	return resources
+}
+
+const (
+	traceTaskgraphResourcesPrefix = "taskgraph.resources."
+)
+
+// acquireResources waits until the tokens for the given resources are available, returning a
+// function which releases them. The wait time is recorded on the span of the context.
+func acquireResources(ctx context.Context, uses map[string]int64) (func(), error) {
+	if len(uses) == 0 {
+		return func() {}, nil
+	}
+	pools := resourcesFromContext(ctx)
+
+	start := time.Now()
+	var acquired []func()
+	release := func() {
+		for i := len(acquired) - 1; i >= 0; i-- {
+			acquired[i]()
+		}
+	}
+	// Acquire the resources in a consistent order to avoid deadlocks between tasks.
+	for _, name := range slices.Sorted(maps.Keys(uses)) {
+		pool, ok := pools[name]
+		if !ok {
+			release()
+			return nil, wrapStackErrorf("%w: %s", ErrUnknownResource, name)
+		}
+		n := uses[name]
+		if err := pool.Acquire(ctx, n); err != nil {
+			release()
+			return nil, wrapStackErrorf("acquiring resource %s: %w", name, err)
+		}
+		acquired = append(acquired, func() { pool.Release(n) })
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64(
+		traceTaskgraphResourcesPrefix+"wait_ms",
+		time.Since(start).Milliseconds(),
+	))
+	return release, nil
+}
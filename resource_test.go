@@ -0,0 +1,183 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+// concurrencyTracker records the maximum number of tasks running at once.
+type concurrencyTracker struct {
+	mu      sync.Mutex
+	running int
+	max     int
+}
+
+func (c *concurrencyTracker) run() {
+	c.mu.Lock()
+	c.running++
+	c.max = max(c.max, c.running)
+	c.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+}
+
+func (c *concurrencyTracker) task(name string) tg.Task {
+	return tg.NoOutputTask(name, func(context.Context, tg.Binder) error {
+		c.run()
+		return nil
+	})
+}
+
+func TestResourceLimitsConcurrency(t *testing.T) {
+	var tracker concurrencyTracker
+	uses := func(name string) tg.TaskSet {
+		return tg.UsesResources{
+			Wrapped:   tracker.task(name),
+			Resources: map[string]int64{"api": 1},
+		}.Locate()
+	}
+	inner := tgt.Must[tg.Graph](t)(tg.New("inner", tg.WithTasks(uses("inner_a"), uses("inner_b"))))
+	g := tgt.Must[tg.Graph](t)(tg.New(
+		"test_graph",
+		tg.WithTasks(uses("a"), uses("b"), uses("c"), tgt.Must[tg.Task](t)(inner.AsTask())),
+		tg.WithResource("api", semaphore.NewWeighted(2)),
+	))
+
+	// The pool is shared by concurrent runs, and with the nested graph.
+	var eg errgroup.Group
+	for range 3 {
+		eg.Go(func() error {
+			_, err := g.Run(context.Background())
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if tracker.max > 2 {
+		t.Errorf("got maximum concurrency %d; want at most 2", tracker.max)
+	}
+}
+
+func TestResourceBuilder(t *testing.T) {
+	var tracker concurrencyTracker
+	var tasks []tg.TaskSet
+	for _, name := range []string{"a", "b", "c"} {
+		tasks = append(tasks, tgt.Must[tg.TaskSet](t)(tg.NewMultiTaskBuilder(name).
+			Run(func() []tg.Binding {
+				tracker.run()
+				return nil
+			}).
+			Uses("api", 2).
+			Build()))
+	}
+	g := tgt.Must[tg.Graph](t)(tg.New(
+		"test_graph",
+		tg.WithTasks(tasks...),
+		tg.WithResource("api", semaphore.NewWeighted(3)),
+	))
+
+	if _, err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tracker.max > 1 {
+		t.Errorf("got maximum concurrency %d; want 1", tracker.max)
+	}
+}
+
+func TestResourceNotUsedWhenSkipped(t *testing.T) {
+	run := tg.NewKey[bool]("run")
+	result := tg.NewKey[string]("result")
+
+	pool := semaphore.NewWeighted(1)
+	// All of the tokens are in use, so the task would wait forever if it acquired them.
+	if err := pool.Acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	g := tgt.Must[tg.Graph](t)(tg.New(
+		"test_graph",
+		tg.WithTasks(tgt.Must[tg.TaskSet](t)(tg.NewTaskBuilder[string]("result", result).
+			Run(func() string { return "ran" }).
+			RunIfAll(run).
+			Default("skipped").
+			Uses("api", 1).
+			Build())),
+		tg.WithResource("api", pool),
+	))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b, err := g.Run(ctx, run.Bind(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt.ExpectPresent(t, b, result, "skipped")
+}
+
+func TestResourceInvalid(t *testing.T) {
+	var tracker concurrencyTracker
+
+	for _, test := range []struct {
+		description string
+		tasks       func() (tg.TaskSet, error)
+	}{
+		{
+			description: "UsesResources",
+			tasks: func() (tg.TaskSet, error) {
+				return tg.UsesResources{
+					Wrapped:   tracker.task("a"),
+					Resources: map[string]int64{"api": 0},
+				}.Locate(), nil
+			},
+		},
+		{
+			description: "builder",
+			tasks: func() (tg.TaskSet, error) {
+				return tg.NewMultiTaskBuilder("a").
+					Run(func() []tg.Binding { return nil }).
+					Uses("api", 2).
+					Uses("api", -1).
+					Build()
+			},
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			tasks, err := test.tasks()
+			if err == nil {
+				_, err = tg.New(
+					"test_graph",
+					tg.WithTasks(tasks),
+					tg.WithResource("api", semaphore.NewWeighted(1)),
+				)
+			}
+			if err == nil {
+				t.Error("expected error for non-positive number of tokens")
+			}
+		})
+	}
+}
+
+func TestResourceUnknown(t *testing.T) {
+	var tracker concurrencyTracker
+	g := tgt.Must[tg.Graph](t)(tg.New("test_graph", tg.WithTasks(tg.UsesResources{
+		Wrapped:   tracker.task("a"),
+		Resources: map[string]int64{"api": 1},
+	}.Locate())))
+
+	if _, err := g.Run(context.Background()); !errors.Is(err, tg.ErrUnknownResource) {
+		t.Errorf("got error %v; want %v", err, tg.ErrUnknownResource)
+	}
+}
@@ -0,0 +1,95 @@
+package taskgraph
+
+import (
+	"context"
+	"sync"
+)
+
+// sharedRuns tracks the runs of a graph started with Graph.RunShared which are in progress.
+type sharedRuns struct {
+	// Protects against concurrent access to runs
+	sync.Mutex
+
+	runs map[string]*sharedRun
+}
+
+// sharedRun is a run of a graph shared by the callers of Graph.RunShared with the same key.
+type sharedRun struct {
+	// done is closed once the run has completed, after setting the result.
+	done chan struct{}
+	b    Binder
+	err  error
+
+	// callers is the number of callers waiting for the run, which is cancelled when it reaches zero.
+	// It is protected by the sharedRuns mutex.
+	callers int
+	cancel  context.CancelFunc
+}
+
+// RunShared is Graph.RunShared.
+func (g *graph) RunShared(ctx context.Context, key string, inputs ...Binding) (Binder, error) {
+	sr := g.joinSharedRun(ctx, key, inputs)
+	select {
+	case <-sr.done:
+		return sr.b, sr.err
+	case <-ctx.Done():
+		g.leaveSharedRun(key, sr)
+		return nil, ctx.Err()
+	}
+}
+
+// joinSharedRun returns the run in progress with the given key, or starts a new one.
+func (g *graph) joinSharedRun(ctx context.Context, key string, inputs []Binding) *sharedRun {
+	g.shared.Lock()
+	defer g.shared.Unlock()
+
+	if sr, ok := g.shared.runs[key]; ok {
+		sr.callers++
+		return sr
+	}
+
+	// The run is only cancelled once every caller has cancelled, so it does not inherit the
+	// cancellation of the caller which started it (but does inherit its values).
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	// As the run is shared, it is not compensated by any of the runs which call it.
+	runCtx = withCompensationLog(runCtx, nil)
+	sr := &sharedRun{
+		done:    make(chan struct{}),
+		callers: 1,
+		cancel:  cancel,
+	}
+	if g.shared.runs == nil {
+		g.shared.runs = map[string]*sharedRun{}
+	}
+	g.shared.runs[key] = sr
+
+	go func() {
+		defer cancel()
+		sr.b, sr.err = g.Run(runCtx, inputs...)
+
+		g.shared.Lock()
+		if g.shared.runs[key] == sr {
+			delete(g.shared.runs, key)
+		}
+		g.shared.Unlock()
+		close(sr.done)
+	}()
+	return sr
+}
+
+// leaveSharedRun removes a caller which has cancelled from the run, cancelling the run if there are
+// no callers left.
+func (g *graph) leaveSharedRun(key string, sr *sharedRun) {
+	g.shared.Lock()
+	defer g.shared.Unlock()
+
+	sr.callers--
+	if sr.callers > 0 {
+		return
+	}
+	sr.cancel()
+	// Later callers start a new run, rather than joining the cancelled one.
+	if g.shared.runs[key] == sr {
+		delete(g.shared.runs, key)
+	}
+}
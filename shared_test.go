@@ -0,0 +1,141 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// waitForCallers waits until n callers have joined the shared run of the graph with the given key.
+func waitForCallers(t *testing.T, g Graph, key string, n int) {
+	t.Helper()
+	shared := &g.(*graph).shared
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		shared.Lock()
+		sr, ok := shared.runs[key]
+		joined := ok && sr.callers >= n
+		shared.Unlock()
+		if joined {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d callers to join the run with key %q", n, key)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunSharedDeduplicates(t *testing.T) {
+	out := NewKey[int]("out")
+	var runs atomic.Int32
+	release := make(chan struct{})
+	g, err := New("test_graph", WithTasks(
+		SimpleTask[int]("count", out, func(context.Context, Binder) (int, error) {
+			n := runs.Add(1)
+			if n == 1 {
+				<-release
+			}
+			return int(n), nil
+		}),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	binders := make([]Binder, 3)
+	var eg errgroup.Group
+	for i := range binders {
+		eg.Go(func() error {
+			b, err := g.RunShared(context.Background(), "obj")
+			binders[i] = b
+			return err
+		})
+	}
+	waitForCallers(t, g, "obj", len(binders))
+	close(release)
+	if err := eg.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := runs.Load(); got != 1 {
+		t.Errorf("got %d runs; want 1", got)
+	}
+	for i, b := range binders {
+		if b != binders[0] {
+			t.Errorf("call %d got a different Binder", i)
+		}
+		if got, err := out.Get(b); err != nil || got != 1 {
+			t.Errorf("call %d: got %d, %v; want 1", i, got, err)
+		}
+	}
+
+	// Calls after the run has completed start a new run, as do calls with other keys.
+	for _, key := range []string{"obj", "other"} {
+		if _, err := g.RunShared(context.Background(), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := runs.Load(); got != 3 {
+		t.Errorf("got %d runs; want 3", got)
+	}
+}
+
+func TestRunSharedCancellation(t *testing.T) {
+	var cancelled atomic.Bool
+	done := make(chan struct{})
+	g, err := New("test_graph", WithTasks(
+		NoOutputTask("wait", func(ctx context.Context, _ Binder) error {
+			<-ctx.Done()
+			cancelled.Store(true)
+			close(done)
+			return ctx.Err()
+		}),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	errs1, errs2 := make(chan error, 1), make(chan error, 1)
+	go func() {
+		_, err := g.RunShared(ctx1, "obj")
+		errs1 <- err
+	}()
+	waitForCallers(t, g, "obj", 1)
+	go func() {
+		_, err := g.RunShared(ctx2, "obj")
+		errs2 <- err
+	}()
+	waitForCallers(t, g, "obj", 2)
+
+	cancel1()
+	if err := <-errs1; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v; want %v", err, context.Canceled)
+	}
+	// The first caller left the run before its call returned, so the run would have been cancelled
+	// (and removed) already if it did not wait for the second caller.
+	shared := &g.(*graph).shared
+	shared.Lock()
+	_, inProgress := shared.runs["obj"]
+	shared.Unlock()
+	if !inProgress || cancelled.Load() {
+		t.Fatal("run cancelled while a caller was still waiting")
+	}
+
+	cancel2()
+	if err := <-errs2; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v; want %v", err, context.Canceled)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run not cancelled after all callers cancelled")
+	}
+}
@@ -0,0 +1,205 @@
+package taskgraph
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// keyInfo records the details of a key created with NewKey or NewNamespacedKey, so that they can
+// be shown alongside the bindings created by the key.
+type keyInfo struct {
+	typ      reflect.Type
+	location string
+	redact   bool
+
+	// validators are the functions added with the WithValidator option.
+	validators []func(any) error
+}
+
+// withKeyInfo annotates a binding created by a key with the key's details.
+func withKeyInfo(b Binding, info *keyInfo) Binding {
+	if bb, ok := b.(*binding); ok {
+		bb.info = info
+	}
+	return b
+}
+
+// keyInfoOf returns the details of the key which created the binding, or nil if they are not known
+// (e.g. for bindings decoded from a checkpoint).
+func keyInfoOf(b Binding) *keyInfo {
+	if bb, ok := b.(*binding); ok {
+		return bb.info
+	}
+	return nil
+}
+
+// Redact prevents the values bound with the key from being shown by Dump or in a SnapshotDiff.
+// This should be used for keys whose values contain secrets.
+func Redact() KeyOption {
+	return func(opts *keyOptions) {
+		opts.redact = true
+	}
+}
+
+// A BinderSnapshot is an immutable copy of the bindings stored in a Binder at a point in time,
+// sorted by ID.
+type BinderSnapshot struct {
+	bindings []Binding
+}
+
+// Snapshot copies the bindings stored in the Binder.
+func Snapshot(b Binder) BinderSnapshot {
+	bindings := b.GetAll()
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].ID().String() < bindings[j].ID().String()
+	})
+	return BinderSnapshot{bindings: bindings}
+}
+
+// Bindings returns the bindings in the snapshot, sorted by ID.
+func (s BinderSnapshot) Bindings() []Binding {
+	return append([]Binding{}, s.bindings...)
+}
+
+// Get returns the binding for the given ID, or a Pending binding if the snapshot does not contain
+// one.
+func (s BinderSnapshot) Get(id ID) Binding {
+	i := sort.Search(len(s.bindings), func(i int) bool {
+		return s.bindings[i].ID().String() >= id.String()
+	})
+	if i < len(s.bindings) && s.bindings[i].ID() == id {
+		return s.bindings[i]
+	}
+	return bindPending(id)
+}
+
+// DumpOptions configures Dump.
+type DumpOptions struct {
+	// MaxValueLength is the maximum number of characters of each value to print; longer values are
+	// truncated. If zero, values are not truncated.
+	MaxValueLength int
+}
+
+// Dump writes a table describing each of the bindings stored in the Binder to w, sorted by ID. For
+// each binding, the table shows its status, the location where its key was defined and the type of
+// its value (if the binding was created by a key created with NewKey or NewNamespacedKey), and its
+// value or error. Values bound with keys created with the Redact option are not shown.
+func Dump(w io.Writer, b Binder, opts DumpOptions) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tLOCATION\tTYPE\tVALUE")
+	for _, binding := range Snapshot(b).bindings {
+		location, typ := "-", "-"
+		if info := keyInfoOf(binding); info != nil {
+			location, typ = info.location, info.typ.String()
+		} else if binding.Status() == Present {
+			typ = fmt.Sprintf("%T", binding.Value())
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%s\t%s\n",
+			binding.ID(),
+			binding.Status(),
+			location,
+			typ,
+			truncate(formatBinding(binding), opts.MaxValueLength),
+		)
+	}
+	return tw.Flush()
+}
+
+// formatBinding returns the value or error of the binding as a string, respecting redaction.
+func formatBinding(b Binding) string {
+	switch b.Status() {
+	case Present:
+		if info := keyInfoOf(b); info != nil && info.redact {
+			return "<redacted>"
+		}
+		return fmt.Sprintf("%v", b.Value())
+	case Absent:
+		return fmt.Sprintf("error: %v", b.Error())
+	}
+	return ""
+}
+
+// truncate shortens s to at most maxLen characters (plus an ellipsis), if maxLen is positive.
+func truncate(s string, maxLen int) string {
+	if maxLen <= 0 || utf8.RuneCountInString(s) <= maxLen {
+		return s
+	}
+	return string([]rune(s)[:maxLen]) + "..."
+}
+
+// A SnapshotDiff describes a binding which differs between two snapshots.
+type SnapshotDiff struct {
+	ID ID
+
+	// Before and After are the bindings for ID in each snapshot; they are Pending bindings if the
+	// snapshot does not contain a binding for ID.
+	Before, After Binding
+}
+
+func (sd SnapshotDiff) String() string {
+	return fmt.Sprintf(
+		"%s: %s(%s) -> %s(%s)",
+		sd.ID,
+		sd.Before.Status(),
+		formatBinding(sd.Before),
+		sd.After.Status(),
+		formatBinding(sd.After),
+	)
+}
+
+// DiffSnapshots returns the bindings which differ between two snapshots (e.g. taken from the
+// results of two runs of the same graph), sorted by ID. Bindings differ if their statuses differ,
+// if they are Present with values which are not equal (according to reflect.DeepEqual), or if they
+// are Absent with errors which have different messages.
+func DiffSnapshots(before, after BinderSnapshot) []SnapshotDiff {
+	ids := map[ID]bool{}
+	for _, b := range before.bindings {
+		ids[b.ID()] = true
+	}
+	for _, b := range after.bindings {
+		ids[b.ID()] = true
+	}
+	sorted := make([]ID, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sortIDs(sorted)
+
+	var res []SnapshotDiff
+	for _, id := range sorted {
+		b, a := before.Get(id), after.Get(id)
+		if bindingsEqual(b, a) {
+			continue
+		}
+		res = append(res, SnapshotDiff{ID: id, Before: b, After: a})
+	}
+	return res
+}
+
+func bindingsEqual(a, b Binding) bool {
+	if a.Status() != b.Status() {
+		return false
+	}
+	switch a.Status() {
+	case Present:
+		return reflect.DeepEqual(a.Value(), b.Value())
+	case Absent:
+		return errorsEqual(a.Error(), b.Error())
+	}
+	return true
+}
+
+// errorsEqual returns whether the errors have the same message, or are both nil (e.g. for bindings
+// created with BindError(nil)).
+func errorsEqual(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Error() == b.Error()
+}
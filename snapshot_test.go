@@ -0,0 +1,134 @@
+package taskgraph_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	tg "github.com/thought-machine/taskgraph"
+)
+
+func TestSnapshot(t *testing.T) {
+	keyA := tg.NewKey[int]("snapshot_a")
+	keyB := tg.NewKey[int]("snapshot_b")
+	b := tg.NewBinder()
+	if err := b.Store(keyB.Bind(2), keyA.Bind(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := tg.Snapshot(b)
+	if err := b.Store(tg.NewKey[int]("snapshot_c").Bind(3)); err != nil {
+		t.Fatal(err)
+	}
+	bindings := snapshot.Bindings()
+	if len(bindings) != 2 || bindings[0].ID() != keyA.ID() || bindings[1].ID() != keyB.ID() {
+		t.Errorf("expected snapshot to contain bindings for %s and %s in order; got %v",
+			keyA.ID(), keyB.ID(), bindings)
+	}
+	if got := snapshot.Get(keyB.ID()); got.Status() != tg.Present || got.Value() != 2 {
+		t.Errorf("got %v; want %v", got, keyB.Bind(2))
+	}
+}
+
+func TestDump(t *testing.T) {
+	keyLong := tg.NewKey[string]("dump_long")
+	keySecret := tg.NewKey[string]("dump_secret", tg.Redact())
+	keyAbsent := tg.NewKey[int]("dump_absent")
+	b := tg.NewBinder()
+	if err := b.Store(
+		keyLong.Bind(strings.Repeat("x", 20)),
+		keySecret.Bind("hunter2"),
+		keyAbsent.BindError(errors.New("no value")),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := tg.Dump(&buf, b, tg.DumpOptions{MaxValueLength: 10}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header and 3 bindings; got:\n%s", buf.String())
+	}
+	for i, want := range [][]string{
+		{"dump_absent", "ABSENT", "snapshot_test.go:", "int", "error: no ..."},
+		{"dump_long", "PRESENT", "string", "xxxxxxxxxx..."},
+		{"dump_secret", "PRESENT", "string", "<redacted>"},
+	} {
+		for _, s := range want {
+			if !strings.Contains(lines[i+1], s) {
+				t.Errorf("expected line %q to contain %q", lines[i+1], s)
+			}
+		}
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("expected redacted value not to be dumped; got:\n%s", buf.String())
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	keySame := tg.NewKey[[]int]("diff_same")
+	keyChanged := tg.NewKey[int]("diff_changed")
+	keyAdded := tg.NewKey[int]("diff_added")
+	keyAbsent := tg.NewKey[int]("diff_absent")
+	keyNilError := tg.NewKey[int]("diff_nil_error")
+
+	before, after := tg.NewBinder(), tg.NewBinder()
+	if err := before.Store(
+		keySame.Bind([]int{1}),
+		keyChanged.Bind(1),
+		keyAbsent.BindAbsent(),
+		keyNilError.BindError(nil),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := after.Store(
+		keySame.Bind([]int{1}),
+		keyChanged.Bind(2),
+		keyAdded.Bind(3),
+		keyAbsent.BindAbsent(),
+		keyNilError.BindAbsent(),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range tg.DiffSnapshots(tg.Snapshot(before), tg.Snapshot(after)) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"diff_added: PENDING() -> PRESENT(3)",
+		"diff_changed: PRESENT(1) -> PRESENT(2)",
+		"diff_nil_error: ABSENT(error: <nil>) -> ABSENT(error: is absent)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got diffs:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDumpKeysWithSameID(t *testing.T) {
+	keySecret := tg.NewKey[string]("dump_shared", tg.Redact())
+	keyPlain := tg.NewKey[string]("dump_shared")
+	for _, test := range []struct {
+		binding tg.Binding
+		want    string
+	}{
+		{binding: keySecret.Bind("hunter2"), want: "<redacted>"},
+		{binding: keyPlain.Bind("visible"), want: "visible"},
+	} {
+		b := tg.NewBinder()
+		if err := b.Store(test.binding); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := tg.Dump(&buf, b, tg.DumpOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		// Each binding is described by the key which created it.
+		if !strings.Contains(buf.String(), test.want) || strings.Contains(buf.String(), "hunter2") {
+			t.Errorf("expected dump to contain %q; got:\n%s", test.want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package taskgraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	set "github.com/deckarep/golang-set/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrDuplicateCase is returned by New if a Switch has multiple cases with the same value.
+var ErrDuplicateCase = errors.New("duplicate switch case")
+
+type switchCase[T comparable] struct {
+	value T
+	tasks TaskSet
+}
+
+// Switch is a TaskSet containing a single task, which runs exactly one of a number of branches,
+// chosen by the value bound to a key. Unlike Conditional, different branches may provide the same
+// keys; the task provides every key provided by any branch, and binds any keys which are not
+// provided by the chosen branch as absent. If no case matches and there is no default branch, all
+// of the keys are bound as absent.
+//
+// Each branch is run as a nested graph (in the same way as a task created by Graph.AsTask), and is
+// validated by New. The task depends on the key, and every key which any branch depends on but
+// does not provide.
+type Switch[T comparable] struct {
+	name        string
+	key         ReadOnlyKey[T]
+	cases       []switchCase[T]
+	defaultCase TaskSet
+	location    string
+}
+
+// NewSwitch creates a Switch on the value bound to the given key. Branches are added with Case
+// and Default.
+func NewSwitch[T comparable](name string, key ReadOnlyKey[T]) Switch[T] {
+	return Switch[T]{
+		name:     name,
+		key:      key,
+		location: getLocation(2),
+	}
+}
+
+// Case adds a branch which is run if the key is bound to the given value.
+func (s Switch[T]) Case(value T, tasks TaskSet) Switch[T] {
+	s.cases = append(append([]switchCase[T]{}, s.cases...), switchCase[T]{value, tasks})
+	return s
+}
+
+// Default sets the branch which is run if the key is bound to a value which does not match any
+// case.
+func (s Switch[T]) Default(tasks TaskSet) Switch[T] {
+	s.defaultCase = tasks
+	return s
+}
+
+const (
+	traceTaskgraphSwitchPrefix = "taskgraph.switch."
+)
+
+// branchName returns the name of the nested graph for a case.
+func (s Switch[T]) branchName(c *switchCase[T]) string {
+	if c == nil {
+		return fmt.Sprintf("%s[default]", s.name)
+	}
+	return fmt.Sprintf("%s[%v]", s.name, c.value)
+}
+
+// branches returns the nested graphs for each case (in the same order as s.cases), and for the
+// default case (which is nil if it is not set).
+func (s Switch[T]) branches() ([]*graph, *graph, error) {
+	var errs error
+	newBranch := func(name string, tasks TaskSet) *graph {
+		g, err := New(name, WithTasks(tasks), WithTracer(contextTracer{}))
+		if err != nil {
+			errs = errors.Join(errs, err)
+			return nil
+		}
+		return g.(*graph)
+	}
+
+	var cases []*graph
+	seen := map[T]bool{}
+	for i, c := range s.cases {
+		if seen[c.value] {
+			errs = errors.Join(errs, wrapStackErrorf("%w: %v", ErrDuplicateCase, c.value))
+		}
+		seen[c.value] = true
+		cases = append(cases, newBranch(s.branchName(&s.cases[i]), c.tasks))
+	}
+	var defaultBranch *graph
+	if s.defaultCase != nil {
+		defaultBranch = newBranch(s.branchName(nil), s.defaultCase)
+	}
+	return cases, defaultBranch, errs
+}
+
+// Tasks satisfies TaskSet.Tasks.
+func (s Switch[T]) Tasks() []Task {
+	t := &task{
+		name:        s.name,
+		location:    s.location,
+		dependDescs: describeKey(s.key),
+	}
+	// The branches are created once, and validated by New.
+	cases, defaultBranch, err := s.branches()
+	t.check = func() error { return err }
+
+	depends, provides := set.NewSet(keyDependencyIDs(s.key)...), set.NewSet[ID]()
+	for _, g := range append(cases, defaultBranch) {
+		if g == nil {
+			continue
+		}
+		external := g.allDependencies.Difference(g.allProvided)
+		depends = depends.Union(external)
+		provides = provides.Union(g.allProvided)
+		for _, bt := range g.tasks {
+			for _, d := range dependencyDescriptors(bt) {
+				if external.Contains(d.ID) && d.Type != nil {
+					t.dependDescs = append(t.dependDescs, d)
+				}
+			}
+			for _, d := range provisionDescriptors(bt) {
+				if d.Type != nil {
+					t.provideDescs = append(t.provideDescs, d)
+				}
+			}
+		}
+	}
+	t.depends = depends.ToSlice()
+	t.provides = provides.ToSlice()
+	sortIDs(t.depends)
+	sortIDs(t.provides)
+
+	t.fn = func(ctx context.Context, b Binder) ([]Binding, error) {
+		if err != nil {
+			return nil, err
+		}
+		return s.execute(ctx, b, t.provides, cases, defaultBranch)
+	}
+	return []Task{t}
+}
+
+func (s Switch[T]) execute(
+	ctx context.Context,
+	b Binder,
+	provides []ID,
+	cases []*graph,
+	defaultBranch *graph,
+) ([]Binding, error) {
+	value, err := s.key.Get(b)
+	if err != nil {
+		return nil, err
+	}
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String(traceTaskgraphSwitchPrefix+"value", fmt.Sprint(value)))
+
+	branch := defaultBranch
+	for i, c := range s.cases {
+		if c.value == value {
+			branch = cases[i]
+			break
+		}
+	}
+	if branch == nil {
+		span.SetAttributes(attribute.String(traceTaskgraphSwitchPrefix+"branch", ""))
+		var res []Binding
+		for _, id := range provides {
+			res = append(res, bindAbsent(id))
+		}
+		return res, nil
+	}
+	span.SetAttributes(attribute.String(traceTaskgraphSwitchPrefix+"branch", branch.name))
+
+	bt, err := branch.AsTask(branch.allProvided.ToSlice()...)
+	if err != nil {
+		return nil, err
+	}
+	// The bindings for the keys provided by the branch are stored in b by the nested graph.
+	if _, err := bt.Execute(ctx, b); err != nil {
+		return nil, err
+	}
+	var res []Binding
+	for _, id := range provides {
+		if !branch.allProvided.Contains(id) {
+			res = append(res, bindAbsent(id))
+		}
+	}
+	return res, nil
+}
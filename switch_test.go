@@ -0,0 +1,106 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tg "github.com/thought-machine/taskgraph"
+	tgt "github.com/thought-machine/taskgraph/taskgraphtest"
+)
+
+func TestSwitch(t *testing.T) {
+	mode := tg.NewKey[string]("mode")
+	in := tg.NewKey[int]("in")
+	mid := tg.NewKey[int]("mid")
+	out := tg.NewKey[int]("out")
+	extra := tg.NewKey[string]("extra")
+
+	multiply := func(factor int) func(context.Context, int) (int, error) {
+		return func(_ context.Context, i int) (int, error) { return i * factor, nil }
+	}
+	sw := tg.NewSwitch[string]("switch", mode).
+		Case("double", tg.SimpleTask1[int, int]("double", out, multiply(2), in)).
+		Case("quadruple", tg.NewTaskSet(
+			tg.SimpleTask1[int, int]("double_1", mid, multiply(2), in),
+			tg.SimpleTask1[int, int]("double_2", out, multiply(2), mid),
+		)).
+		Default(tg.NewTaskSet(
+			tg.SimpleTask1[int, int]("identity", out, multiply(1), in),
+			tg.SimpleTask[string]("extra", extra, func(_ context.Context, _ tg.Binder) (string, error) {
+				return "default", nil
+			}),
+		))
+
+	tgt.Suite{
+		Task: sw,
+		Tests: []tgt.Test{
+			{
+				Description: "case",
+				Inputs:      []tg.Binding{mode.Bind("double"), in.Bind(3)},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(out.Bind(6)),
+					tgt.Match(mid.BindAbsent()),
+					tgt.Match(extra.BindAbsent()),
+				},
+				CheckExcessBindings: true,
+			},
+			{
+				Description: "case with multiple tasks",
+				Inputs:      []tg.Binding{mode.Bind("quadruple"), in.Bind(3)},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(out.Bind(12)),
+					tgt.Match(mid.Bind(6)),
+					tgt.Match(extra.BindAbsent()),
+				},
+				CheckExcessBindings: true,
+			},
+			{
+				Description: "default",
+				Inputs:      []tg.Binding{mode.Bind("other"), in.Bind(3)},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(out.Bind(3)),
+					tgt.Match(mid.BindAbsent()),
+					tgt.Match(extra.Bind("default")),
+				},
+				CheckExcessBindings: true,
+			},
+		},
+	}.Run(t)
+
+	tgt.Test{
+		Task: tg.NewSwitch[string]("switch", mode).
+			Case("double", tg.SimpleTask1[int, int]("double", out, multiply(2), in)),
+		Inputs: []tg.Binding{mode.Bind("other"), in.Bind(3)},
+		WantBindings: []tgt.BindingMatcher{
+			tgt.Match(out.BindAbsent()),
+		},
+	}.Run(t)
+}
+
+func TestSwitchErrors(t *testing.T) {
+	mode := tg.NewKey[int]("mode")
+	a := tg.NewKey[int]("a")
+	b := tg.NewKey[int]("b")
+	identity := func(_ context.Context, i int) (int, error) { return i, nil }
+
+	t.Run("ErrDuplicateCase", func(t *testing.T) {
+		if _, err := tg.New("test_graph", tg.WithTasks(tg.NewSwitch[int]("switch", mode).
+			Case(1, tg.SimpleTask1[int, int]("one", a, identity, mode)).
+			Case(1, tg.SimpleTask1[int, int]("two", a, identity, mode)),
+		)); !errors.Is(err, tg.ErrDuplicateCase) {
+			t.Errorf("expected error %v; got %v", tg.ErrDuplicateCase, err)
+		}
+	})
+
+	t.Run("ErrGraphCycle", func(t *testing.T) {
+		if _, err := tg.New("test_graph", tg.WithTasks(tg.NewSwitch[int]("switch", mode).
+			Case(1, tg.NewTaskSet(
+				tg.SimpleTask1[int, int]("a", a, identity, b),
+				tg.SimpleTask1[int, int]("b", b, identity, a),
+			)),
+		)); !errors.Is(err, tg.ErrGraphCycle) {
+			t.Errorf("expected error %v; got %v", tg.ErrGraphCycle, err)
+		}
+	})
+}
@@ -2,6 +2,8 @@ package taskgraph
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	set "github.com/deckarep/golang-set/v2"
 	"go.opentelemetry.io/otel/attribute"
@@ -50,12 +52,83 @@ type Task interface {
 	Location() string
 }
 
+// TypedTask is an optional interface implemented by Tasks which can describe the keys they depend
+// on and provide, including the types of the values bound to those keys where known. All tasks
+// created by this package implement it.
+type TypedTask interface {
+	Task
+
+	// DependencyDescriptors returns descriptors for the keys on which this task depends. Every ID
+	// returned by Depends() is described at least once.
+	DependencyDescriptors() []KeyDescriptor
+	// ProvisionDescriptors returns descriptors for the keys which this task provides. Every ID
+	// returned by Provides() is described at least once.
+	ProvisionDescriptors() []KeyDescriptor
+}
+
+// dependencyDescriptors returns descriptors for the dependencies of any task, falling back to
+// untyped descriptors for tasks which do not implement TypedTask.
+func dependencyDescriptors(t Task) []KeyDescriptor {
+	if tt, ok := t.(TypedTask); ok {
+		return tt.DependencyDescriptors()
+	}
+	return describeIDs(t.Depends(), nil)
+}
+
+// provisionDescriptors returns descriptors for the keys provided by any task, falling back to
+// untyped descriptors for tasks which do not implement TypedTask.
+func provisionDescriptors(t Task) []KeyDescriptor {
+	if tt, ok := t.(TypedTask); ok {
+		return tt.ProvisionDescriptors()
+	}
+	return describeIDs(t.Provides(), nil)
+}
+
 type task struct {
 	name     string
 	depends  []ID
 	provides []ID
 	fn       func(context.Context, Binder) ([]Binding, error)
 	location string
+
+	// dependDescs and provideDescs describe (a subset of) depends and provides, where the keys are
+	// known when the task is created.
+	dependDescs, provideDescs []KeyDescriptor
+
+	// check, if set, is called by New to validate the task, for tasks which are built from
+	// configuration that cannot be validated when the task is created.
+	check func() error
+
+	// sideEffect is set for tasks wrapped in a SideEffect.
+	sideEffect bool
+	// finally is set for tasks wrapped in a Finally.
+	finally *finallyOptions
+	// userFn is the function from which the task was built (e.g. passed to SimpleTask), which is
+	// used as the stack of errors returned by the task without one (see TaskError.Frames).
+	userFn any
+	// optionalDepends are keys which the task reads if they are bound when it starts, but which
+	// need not be bound (such as the inputs with defaults of a graph run with AsTask). New makes the
+	// task depend on those which are provided by other tasks in the graph.
+	optionalDepends []ID
+}
+
+// copyTask returns a *task with the same metadata and behaviour as t, which can then be modified to
+// wrap or decorate t without affecting it.
+func copyTask(t Task) *task {
+	if tt, ok := t.(*task); ok {
+		c := *tt
+		return &c
+	}
+	return &task{
+		name:         t.Name(),
+		depends:      t.Depends(),
+		provides:     t.Provides(),
+		fn:           t.Execute,
+		location:     t.Location(),
+		dependDescs:  dependencyDescriptors(t),
+		provideDescs: provisionDescriptors(t),
+		userFn:       executeMethod(t),
+	}
 }
 
 func (t *task) Tasks() []Task {
@@ -82,6 +155,14 @@ func (t *task) Location() string {
 	return t.location
 }
 
+func (t *task) DependencyDescriptors() []KeyDescriptor {
+	return describeIDs(t.depends, t.dependDescs)
+}
+
+func (t *task) ProvisionDescriptors() []KeyDescriptor {
+	return describeIDs(t.provides, t.provideDescs)
+}
+
 // NewTask builds a task with any number of inputs and outputs.
 func NewTask(
 	name string,
@@ -94,6 +175,7 @@ func NewTask(
 		provides: provides,
 		fn:       fn,
 		location: getLocation(2),
+		userFn:   fn,
 	}
 }
 
@@ -106,6 +188,7 @@ func NoOutputTask(name string, fn func(ctx context.Context, b Binder) error, dep
 			return nil, fn(ctx, b)
 		},
 		location: getLocation(2),
+		userFn:   fn,
 	}
 }
 
@@ -127,7 +210,9 @@ func SimpleTask[T any](
 			}
 			return []Binding{key.Bind(val)}, nil
 		},
-		location: getLocation(2),
+		location:     getLocation(2),
+		userFn:       fn,
+		provideDescs: describeKey(key),
 	}
 }
 
@@ -140,7 +225,7 @@ func SimpleTask1[A1, Res any](
 ) Task {
 	return &task{
 		name:     name,
-		depends:  []ID{depKey1.ID()},
+		depends:  keyDependencyIDs(depKey1),
 		provides: []ID{resKey.ID()},
 		fn: func(ctx context.Context, b Binder) ([]Binding, error) {
 			arg1, err := depKey1.Get(b)
@@ -153,7 +238,10 @@ func SimpleTask1[A1, Res any](
 			}
 			return []Binding{resKey.Bind(res)}, nil
 		},
-		location: getLocation(2),
+		location:     getLocation(2),
+		userFn:       fn,
+		dependDescs:  describeKey(depKey1),
+		provideDescs: describeKey(resKey),
 	}
 }
 
@@ -167,7 +255,7 @@ func SimpleTask2[A1, A2, Res any](
 ) Task {
 	return &task{
 		name:     name,
-		depends:  []ID{depKey1.ID(), depKey2.ID()},
+		depends:  append(keyDependencyIDs(depKey1), keyDependencyIDs(depKey2)...),
 		provides: []ID{resKey.ID()},
 		fn: func(ctx context.Context, b Binder) ([]Binding, error) {
 			arg1, err := depKey1.Get(b)
@@ -184,7 +272,10 @@ func SimpleTask2[A1, A2, Res any](
 			}
 			return []Binding{resKey.Bind(res)}, nil
 		},
-		location: getLocation(2),
+		location:     getLocation(2),
+		userFn:       fn,
+		dependDescs:  describeKeys[any](depKey1, depKey2),
+		provideDescs: describeKey(resKey),
 	}
 }
 
@@ -194,7 +285,8 @@ type Condition interface {
 	Evaluate(ctx context.Context, b Binder) (bool, error)
 	// Deps should return the IDs of the keys used by the Evaluate function.
 	Deps() []ID
-	// Keys returns the keys in the conditional
+	// Keys returns the boolean keys in the conditional (conditions on keys of other types, such as
+	// those created by When, return no keys).
 	Keys() []ReadOnlyKey[bool]
 }
 
@@ -215,11 +307,15 @@ func (ca ConditionAnd) Evaluate(_ context.Context, b Binder) (bool, error) {
 	return true, nil
 }
 
+func (ca ConditionAnd) evaluateTrace(_ context.Context, b Binder) (bool, string, error) {
+	return evaluateKeys("and", ca, b, true)
+}
+
 // Deps is Condition.Deps
 func (ca ConditionAnd) Deps() []ID {
 	var deps []ID
 	for _, k := range ca {
-		deps = append(deps, k.ID())
+		deps = append(deps, keyDependencyIDs(k)...)
 	}
 	return deps
 }
@@ -246,11 +342,15 @@ func (co ConditionOr) Evaluate(_ context.Context, b Binder) (bool, error) {
 	return false, nil
 }
 
+func (co ConditionOr) evaluateTrace(_ context.Context, b Binder) (bool, string, error) {
+	return evaluateKeys("or", co, b, false)
+}
+
 // Deps is Condition.Deps
 func (co ConditionOr) Deps() []ID {
 	var deps []ID
 	for _, k := range co {
-		deps = append(deps, k.ID())
+		deps = append(deps, keyDependencyIDs(k)...)
 	}
 	return deps
 }
@@ -260,6 +360,25 @@ func (co ConditionOr) Keys() []ReadOnlyKey[bool] {
 	return co
 }
 
+// evaluateKeys evaluates a list of boolean keys, stopping at the first key which is not bound to
+// all, for ConditionAnd (where all is true) and ConditionOr (where all is false).
+func evaluateKeys(name string, keys []ReadOnlyKey[bool], b Binder, all bool) (bool, string, error) {
+	var traces []string
+	for _, k := range keys {
+		v, err := k.Get(b)
+		traces = append(traces, traceResult(k.ID().String(), v, err))
+		expr := fmt.Sprintf("%s(%s)", name, strings.Join(traces, ", "))
+		if err != nil {
+			return false, traceResult(expr, false, err), err
+		}
+		if v != all {
+			return v, traceResult(expr, v, nil), nil
+		}
+	}
+	expr := fmt.Sprintf("%s(%s)", name, strings.Join(traces, ", "))
+	return all, traceResult(expr, all, nil), nil
+}
+
 // Conditional wraps tasks such that they are only run if given Condition evaluates to true. If it
 // evaluates to false, the bindings in DefaultBindings are used, with any missing keys provided by
 // the wrapped tasks bound as absent.
@@ -268,7 +387,8 @@ func (co ConditionOr) Keys() []ReadOnlyKey[bool] {
 // condition's dependencies have been bound.
 //
 // To run tasks if keys of any type have been bound to some value (i.e. not bound as absent), use
-// Presence() to wrap the key. To check for specific values, use Mapped() to wrap the key.
+// IsPresent(). To check for specific values, use When(). Conditions can be combined with And(),
+// Or() and NotCond(). A trace of the evaluation of the condition is recorded on the task's span.
 type Conditional struct {
 	NamePrefix      string
 	Wrapped         TaskSet
@@ -300,49 +420,37 @@ func (c Conditional) Tasks() []Task {
 		t := t
 		allDeps := set.NewSet[ID](t.Depends()...)
 		allDeps.Append(c.Condition.Deps()...)
-		res = append(res, &task{
-			name:     c.NamePrefix + t.Name(),
-			depends:  allDeps.ToSlice(),
-			provides: t.Provides(),
-			fn: func(ctx context.Context, b Binder) ([]Binding, error) {
-				shouldExecute, err := c.Condition.Evaluate(ctx, b)
-				if err != nil {
-					return nil, err
-				}
-				span := trace.SpanFromContext(ctx)
-				span.SetAttributes(
-					attribute.Bool(traceTaskgraphConditionalPrefix+"execute", shouldExecute),
-				)
-				for _, k := range c.Condition.Keys() {
-					v, err := k.Get(b)
-					if err != nil {
-						span.SetAttributes(
-							attribute.String(
-								traceTaskgraphConditionalPrefix+k.ID().String(),
-								err.Error(),
-							),
-						)
-					} else {
-						span.SetAttributes(
-							attribute.Bool(traceTaskgraphConditionalPrefix+k.ID().String(), v),
-						)
-					}
-				}
-				if shouldExecute {
-					return t.Execute(ctx, b)
-				}
-				var res []Binding
-				for _, id := range t.Provides() {
-					if b, ok := defaultBindingsMap[id]; ok {
-						res = append(res, b)
-					} else {
-						res = append(res, bindAbsent(id))
-					}
+		ct := copyTask(t)
+		ct.name = c.NamePrefix + t.Name()
+		ct.depends = allDeps.ToSlice()
+		ct.dependDescs = append(dependencyDescriptors(t), describeCondition(c.Condition)...)
+		ct.location = c.location
+		ct.fn = func(ctx context.Context, b Binder) ([]Binding, error) {
+			shouldExecute, conditionTrace, err := evaluateCondition(ctx, c.Condition, b)
+			span := trace.SpanFromContext(ctx)
+			span.SetAttributes(
+				attribute.String(traceTaskgraphConditionalPrefix+"trace", conditionTrace),
+			)
+			if err != nil {
+				return nil, err
+			}
+			span.SetAttributes(
+				attribute.Bool(traceTaskgraphConditionalPrefix+"execute", shouldExecute),
+			)
+			if shouldExecute {
+				return t.Execute(ctx, b)
+			}
+			var res []Binding
+			for _, id := range t.Provides() {
+				if b, ok := defaultBindingsMap[id]; ok {
+					res = append(res, b)
+				} else {
+					res = append(res, bindAbsent(id))
 				}
-				return res, nil
-			},
-			location: c.location,
-		})
+			}
+			return res, nil
+		}
+		res = append(res, ct)
 	}
 	return res
 }
@@ -358,6 +466,7 @@ func AllBound(name string, result Key[bool], deps ...ID) Task {
 		fn: func(_ context.Context, _ Binder) ([]Binding, error) {
 			return []Binding{result.Bind(true)}, nil
 		},
-		location: getLocation(2),
+		location:     getLocation(2),
+		provideDescs: describeKey(result),
 	}
 }
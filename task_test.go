@@ -228,6 +228,35 @@ func TestTasks(t *testing.T) {
 				},
 				CheckExcessBindings: true,
 			},
+			{
+				Description: "Conditional, composed condition",
+				Task: tg.Conditional{
+					NamePrefix: "cond_",
+					Wrapped: tg.SimpleTask1[string, string](
+						"task",
+						key2,
+						func(_ context.Context, arg1 string) (string, error) {
+							return arg1 + arg1, nil
+						},
+						key1,
+					),
+					Condition: tg.And(
+						tg.When(key3, func(v string) bool { return v == "yes" }),
+						tg.NotCond(tg.ConditionAnd{boolKey}),
+					),
+				}.Locate(),
+				Inputs: []tg.Binding{
+					key1.Bind("bar"),
+					key3.Bind("yes"),
+					boolKey.Bind(false),
+				},
+				WantBindings: []tgt.BindingMatcher{
+					tgt.Match(key2.Bind("barbar")),
+				},
+				CheckExcessBindings:     true,
+				StrictDependencies:      true,
+				CheckUnusedDependencies: true,
+			},
 			{
 				Description: "Reflect",
 				Task: tg.Reflect[string]{
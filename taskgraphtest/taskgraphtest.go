@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -195,6 +196,45 @@ func MatchFunc[T any](want tg.Binding, compareFn func(got, want T) error) Bindin
 	}}
 }
 
+// keyMatcher is a BindingMatcher which reads a (possibly virtual) key from the binder, rather than
+// matching a single binding.
+type keyMatcher struct {
+	bindingMatcher
+	dependencyIDs []tg.ID
+	matchBinder   func(b tg.Binder) error
+}
+
+// MatchKey creates a BindingMatcher which checks the value returned by key.Get using cmp.Diff with
+// the given options. Unlike the other matchers, this can be used with virtual keys such as those
+// created by tg.Presence(); when checking for excess bindings, the bindings for all of the key's
+// dependency IDs (see tg.DependencyIDs) are expected.
+func MatchKey[T any](key tg.ReadOnlyKey[T], want T, opts ...cmp.Option) BindingMatcher {
+	matchBinder := func(b tg.Binder) error {
+		got, err := key.Get(b)
+		if err != nil {
+			return fmt.Errorf("cannot get key %s: %w", key.ID(), err)
+		}
+		if diff := cmp.Diff(want, got, opts...); diff != "" {
+			return fmt.Errorf("difference in key %s value (-want, +got):\n%s", key.ID(), diff)
+		}
+		return nil
+	}
+	return keyMatcher{
+		// When matching a single binding, only the binding itself can be read.
+		bindingMatcher: bindingMatcher{key.ID(), func(got tg.Binding) error {
+			b := tg.NewBinder()
+			if got.Status() != tg.Pending {
+				if err := b.Store(got); err != nil {
+					return err
+				}
+			}
+			return matchBinder(b)
+		}},
+		dependencyIDs: tg.DependencyIDs(key),
+		matchBinder:   matchBinder,
+	}
+}
+
 // ExpectBindings checks if the binder contains bindings which match the given matchers.
 func ExpectBindings(t *testing.T, b tg.Binder, want []BindingMatcher) {
 	t.Helper()
@@ -203,8 +243,7 @@ func ExpectBindings(t *testing.T, b tg.Binder, want []BindingMatcher) {
 }
 
 // ExpectExactBindings checks if the binder contains bindings which match the given matchers, and no
-// other bindings. Note that this will not play nicely with virtual bindings such as those generated
-// by tg.Presence().
+// other bindings. To match virtual keys such as those generated by tg.Presence(), use MatchKey.
 func ExpectExactBindings(t *testing.T, b tg.Binder, want []BindingMatcher) {
 	t.Helper()
 
@@ -214,8 +253,17 @@ func ExpectExactBindings(t *testing.T, b tg.Binder, want []BindingMatcher) {
 func expectBindings(t *testing.T, b tg.Binder, want []BindingMatcher, exact bool) {
 	t.Helper()
 
+	wantIDs := set.NewSet[tg.ID]()
 	for _, matcher := range want {
-		if err := matcher.Match(b.Get(matcher.ID())); err != nil {
+		var err error
+		if km, ok := matcher.(keyMatcher); ok {
+			err = km.matchBinder(b)
+			wantIDs.Append(km.dependencyIDs...)
+		} else {
+			err = matcher.Match(b.Get(matcher.ID()))
+			wantIDs.Add(matcher.ID())
+		}
+		if err != nil {
 			t.Error(err)
 		}
 	}
@@ -224,11 +272,7 @@ func expectBindings(t *testing.T, b tg.Binder, want []BindingMatcher, exact bool
 		allBindings := b.GetAll()
 		// The individual WantBindings will report any missing bindings, so we're only checking for
 		// excess rather than inequality.
-		if len(allBindings) > len(want) {
-			wantIDs := set.NewSet[tg.ID]()
-			for _, wb := range want {
-				wantIDs.Add(wb.ID())
-			}
+		if len(allBindings) > wantIDs.Cardinality() {
 			var excess []string
 			for _, b := range allBindings {
 				if !wantIDs.Contains(b.ID()) {
@@ -279,18 +323,42 @@ type Test struct {
 
 	// CheckExcessBindings, if true, causes the graph result to be checked for the presence of
 	// bindings not in WantBindings. This option should be used sparingly, as it can lead to fragile
-	// tests. Note that this will not play nicely with virtual bindings such as those generated
-	// by tg.Presence().
+	// tests. To match virtual keys such as those generated by tg.Presence(), use MatchKey.
 	CheckExcessBindings bool
 
 	// WantInputBindings defines matchers on input bindings (i.e. those in Inputs) which are checked
 	// after the graph is run. This can be used to check for side effects on inputs.
 	WantInputBindings []BindingMatcher
 
+	// StrictDependencies, if true, causes the test to fail if any task reads a key which it has not
+	// declared as a dependency (see taskgraph.WithStrictDependencies). This is only supported when
+	// Task is set (on the Test or its Suite, in which case a Graph is created for this test rather
+	// than sharing the Suite's Graph); to test a Graph, pass FailOnViolations to
+	// taskgraph.WithStrictDependencies when creating it.
+	StrictDependencies bool
+
+	// CheckUnusedDependencies, if true, causes the test to fail if any task declares a dependency
+	// which it does not read. This is only supported when Task is set, and should not be used with
+	// Conditional tasks whose condition is not met.
+	CheckUnusedDependencies bool
+
 	// How long the task/graph should be allowed to run. If unset, defaults to 10 seconds
 	Timeout time.Duration
 }
 
+// violationRecorder records the dependency violations reported by a graph.
+type violationRecorder struct {
+	sync.Mutex
+	violations []tg.DependencyViolation
+}
+
+func (vr *violationRecorder) record(v tg.DependencyViolation) {
+	vr.Lock()
+	defer vr.Unlock()
+
+	vr.violations = append(vr.violations, v)
+}
+
 // Run the test.
 func (test Test) Run(t *testing.T) {
 	if test.Graph != nil && test.Task != nil {
@@ -300,9 +368,21 @@ func (test Test) Run(t *testing.T) {
 		t.Fatal("Invalid Test: no Graph or Task set")
 	}
 
+	if test.Graph != nil && (test.StrictDependencies || test.CheckUnusedDependencies) {
+		t.Fatal("Invalid Test: dependency checks are only supported when Task is set")
+	}
+
+	violations := &violationRecorder{}
 	if test.Task != nil {
+		opts := []tg.GraphOption{tg.WithTasks(test.Task)}
+		if test.StrictDependencies || test.CheckUnusedDependencies {
+			opts = append(opts, tg.WithStrictDependencies(tg.StrictDependencies{
+				RecordOnly: !test.StrictDependencies,
+				Report:     violations.record,
+			}))
+		}
 		var err error
-		test.Graph, err = tg.New("test_graph", tg.WithTasks(test.Task))
+		test.Graph, err = tg.New("test_graph", opts...)
 		if err != nil {
 			t.Fatalf("Failed to create Graph from Task: %v", err)
 		}
@@ -316,6 +396,13 @@ func (test Test) Run(t *testing.T) {
 
 	result, err := test.Graph.Run(ctx, test.Inputs...)
 
+	for _, v := range violations.violations {
+		if (v.Kind == tg.UndeclaredDependency && test.StrictDependencies) ||
+			(v.Kind == tg.UnusedDependency && test.CheckUnusedDependencies) {
+			t.Errorf("Dependency violation: %s", v)
+		}
+	}
+
 	if !errors.Is(err, test.WantError) {
 		t.Fatalf("Difference in error from Graph.Run(): got %v; want %v", err, test.WantError)
 	}
@@ -377,7 +464,12 @@ func (s Suite) Run(t *testing.T) {
 
 	for _, test := range s.Tests {
 		if test.Graph == nil && test.Task == nil {
-			test.Graph = s.Graph
+			if s.Task != nil && (test.StrictDependencies || test.CheckUnusedDependencies) {
+				// The dependency checks require a Graph created for the test.
+				test.Task = s.Task
+			} else {
+				test.Graph = s.Graph
+			}
 		}
 		if test.Timeout <= 0 {
 			test.Timeout = s.Timeout
@@ -386,6 +478,14 @@ func (s Suite) Run(t *testing.T) {
 	}
 }
 
+// FailOnViolations returns a function suitable for use as taskgraph.StrictDependencies.Report,
+// which fails the test for every dependency violation reported.
+func FailOnViolations(t *testing.T) func(tg.DependencyViolation) {
+	return func(v tg.DependencyViolation) {
+		t.Errorf("Dependency violation: %s", v)
+	}
+}
+
 // DummyTaskFunc returns a function suitable for passing to taskgraph.NewTask which returns the
 // given bindings.
 func DummyTaskFunc(bindings ...tg.Binding) func(context.Context, tg.Binder) ([]tg.Binding, error) {
@@ -1,6 +1,7 @@
 package taskgraph
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
@@ -8,6 +9,8 @@ import (
 
 	stackerrors "github.com/go-errors/errors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
 )
 
 var (
@@ -161,3 +164,24 @@ func wrapStackErrorf(msg string, args ...any) error {
 	}
 	return stackerrors.Wrap(err, 1)
 }
+
+// tracerFromContext returns a tracer from the provider of the span in the context, so that spans
+// created within a task are recorded by the tracer passed to WithTracer.
+func tracerFromContext(ctx context.Context) trace.Tracer {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer("github.com/thought-machine/taskgraph")
+}
+
+// contextTracer is a trace.Tracer which starts spans with the tracer from the context (see
+// tracerFromContext), so that graphs which are created once by a TaskSet and run within a task
+// (such as the body of a Loop) are recorded by the tracer of the graph containing the task.
+type contextTracer struct {
+	embedded.Tracer
+}
+
+func (contextTracer) Start(
+	ctx context.Context,
+	name string,
+	opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	return tracerFromContext(ctx).Start(ctx, name, opts...)
+}
@@ -0,0 +1,59 @@
+package taskgraph
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidBinding is returned when a value bound to a key created with the WithValidator option
+// is rejected by the validator, either as an input to a graph or as a binding returned by a task.
+var ErrInvalidBinding = errors.New("invalid binding")
+
+// WithValidator adds a function which validates values bound with the key. It is run when a binding
+// returned by a task is stored, failing the task if the value is invalid, and when the inputs to a
+// graph are stored. Bindings which are absent are not validated.
+//
+// The validators belong to the key value: if multiple keys are created with the same ID, only the
+// validators of the key which created a binding are run for it.
+func WithValidator[T any](fn func(T) error) KeyOption {
+	return func(opts *keyOptions) {
+		opts.validators = append(opts.validators, func(v any) error {
+			typed, ok := v.(T)
+			if !ok {
+				// Reported as ErrWrongType when the key is read.
+				return nil
+			}
+			return fn(typed)
+		})
+	}
+}
+
+// validateBindings runs the validators of the keys of the given bindings, returning an error
+// wrapping ErrInvalidBinding for each invalid value.
+func validateBindings(bindings []Binding) error {
+	var errs error
+	for _, binding := range bindings {
+		if binding.Status() != Present {
+			continue
+		}
+		info := keyInfoOf(binding)
+		if info == nil {
+			continue
+		}
+		for _, validate := range info.validators {
+			if err := validate(binding.Value()); err != nil {
+				errs = errors.Join(errs, fmt.Errorf(
+					"%w: key %s (%s): %w",
+					ErrInvalidBinding,
+					binding.ID(),
+					info.location,
+					err,
+				))
+			}
+		}
+	}
+	if errs != nil {
+		return wrapStackErrorf("%w", errs)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package taskgraph_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	tg "github.com/thought-machine/taskgraph"
+)
+
+func TestValidator(t *testing.T) {
+	errNegative := errors.New("must not be negative")
+	keyReplicas := tg.NewKey[int]("validate_replicas", tg.WithValidator(func(v int) error {
+		if v < 0 {
+			return errNegative
+		}
+		return nil
+	}))
+	keyName := tg.NewKey[string]("validate_name", tg.WithValidator(func(v string) error {
+		if v == "" {
+			return errors.New("must not be empty")
+		}
+		return nil
+	}))
+	keyOut := tg.NewKey[string]("validate_out")
+
+	g, err := tg.New("validate", tg.WithTasks(
+		tg.SimpleTask("replicas", keyReplicas, func(_ context.Context, b tg.Binder) (int, error) {
+			name, err := keyName.Get(b)
+			if err != nil {
+				return 0, err
+			}
+			return len(name) - 3, nil
+		}, keyName.ID()),
+		tg.SimpleTask1("out", keyOut, func(_ context.Context, replicas int) (string, error) {
+			return strings.Repeat("x", replicas), nil
+		}, keyReplicas),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := g.Run(context.Background(), keyName.Bind("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := keyOut.Get(b); got != "x" {
+		t.Errorf("got %q; want %q", got, "x")
+	}
+
+	_, err = g.Run(context.Background(), keyName.Bind("ab"))
+	if !errors.Is(err, tg.ErrInvalidBinding) || !errors.Is(err, errNegative) {
+		t.Fatalf("expected error wrapping ErrInvalidBinding and the validator's error; got %v", err)
+	}
+	for _, want := range []string{"task replicas", "validate_replicas", "validate_test.go:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q; got %v", want, err)
+		}
+	}
+
+	_, err = g.Run(context.Background(), keyName.Bind(""))
+	if !errors.Is(err, tg.ErrInvalidBinding) || !strings.Contains(err.Error(), "input") {
+		t.Errorf("expected invalid input error; got %v", err)
+	}
+
+	// Absent bindings are not validated.
+	if err := g.Check(keyName.BindAbsent()); err != nil {
+		t.Errorf("expected absent input to be valid; got %v", err)
+	}
+}
+
+func TestValidatorKeysWithSameID(t *testing.T) {
+	calls := 0
+	newKey := func() tg.Key[int] {
+		return tg.NewKey[int]("validate_shared", tg.WithValidator(func(int) error {
+			calls++
+			return nil
+		}))
+	}
+	// Creating more keys with the same ID does not add to the validators of the first.
+	key := newKey()
+	for range 3 {
+		newKey()
+	}
+	plain := tg.NewKey[int]("validate_shared")
+	g, err := tg.New("validate", tg.WithTasks(
+		tg.NoOutputTask("read", func(context.Context, tg.Binder) error { return nil }, key.ID()),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Run(context.Background(), key.Bind(1)); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d validator calls; want 1", calls)
+	}
+	if _, err := g.Run(context.Background(), plain.Bind(1)); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d validator calls for a key without validators; want 1", calls)
+	}
+}